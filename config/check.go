@@ -0,0 +1,379 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mecha/tags/rules"
+	"github.com/mecha/tags/utils"
+)
+
+type (
+	// Problem is an error or warning found in a config file.
+	Problem struct {
+		Path     string
+		Pos      Position
+		Tag      string
+		Fragment string
+		Rule     string
+		Message  string
+		Warning  bool
+	}
+
+	checker struct {
+		problems []Problem
+		seen     map[string]bool
+		// checked holds the fragments of the files that have been checked.
+		checked map[string]fragments
+	}
+
+	// location identifies where in a config file a problem was found.
+	location struct {
+		keys     []string
+		tag      string
+		fragment string
+		rule     string
+	}
+
+	// fileChecker collects the problems found in a single config file.
+	fileChecker struct {
+		path      string
+		positions positions
+		problems  []Problem
+	}
+)
+
+func (p Problem) String() string {
+	sb := strings.Builder{}
+	sb.WriteString(p.Path)
+
+	if p.Pos.IsValid() {
+		fmt.Fprintf(&sb, ":%d:%d", p.Pos.Line, p.Pos.Col)
+	}
+
+	if p.Warning {
+		sb.WriteString(": warning: ")
+	} else {
+		sb.WriteString(": error: ")
+	}
+
+	if p.Tag != "" {
+		fmt.Fprintf(&sb, "tag \"%s\": ", p.Tag)
+	}
+
+	if p.Fragment != "" {
+		fmt.Fprintf(&sb, "fragment \"%s\": ", p.Fragment)
+	}
+
+	if p.Rule != "" {
+		fmt.Fprintf(&sb, "[%s] ", p.Rule)
+	}
+
+	sb.WriteString(p.Message)
+
+	return sb.String()
+}
+
+// Check validates config files and the files that they include. Unlike Read,
+// it does not stop at the first problem, and it also reports the keys that
+// are not used by any rule type as warnings.
+func Check(paths []string) []Problem {
+	c := checker{
+		seen:    make(map[string]bool),
+		checked: make(map[string]fragments),
+	}
+
+	for _, path := range paths {
+		c.checkFile(path, nil)
+	}
+
+	return c.problems
+}
+
+// HasErrors reports whether any of the problems is an error.
+func HasErrors(problems []Problem) bool {
+	for _, p := range problems {
+		if !p.Warning {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkFile checks a config file and the files that it includes, and returns
+// the fragments that are available in it. The stack holds the files that are
+// being included, to detect cycles.
+func (c *checker) checkFile(path string, stack []string) fragments {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+
+	if frags, ok := c.checked[abs]; ok {
+		return frags
+	}
+
+	frags := make(fragments)
+	c.checked[abs] = frags
+	f := fileChecker{path: path}
+
+	defer func() {
+		f.sort()
+		c.add(f.problems...)
+	}()
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		f.errorf(location{}, "%s", err)
+		return frags
+	}
+
+	format, err := FormatOf(path)
+	if err != nil {
+		f.errorf(location{}, "%s", err)
+		return frags
+	}
+
+	doc, err := decode(format, raw)
+	if err != nil {
+		f.problems = append(f.problems, Problem{
+			Path:    path,
+			Pos:     errorPosition(raw, err),
+			Message: err.Error(),
+		})
+		return frags
+	}
+
+	f.positions = indexPositions(format, raw)
+	stack = append(stack, abs)
+
+	if _, err := migrate(doc); err != nil {
+		f.errorf(location{keys: []string{VersionKey}}, "%s", err)
+		return frags
+	}
+
+	includes, err := includePaths(abs, doc[IncludeKey])
+	if err != nil {
+		f.errorf(location{keys: []string{IncludeKey}}, "%s", err)
+	}
+
+	for _, include := range includes {
+		if contains(stack, include) {
+			f.errorf(location{keys: []string{IncludeKey}}, "include cycle: %s -> %s", strings.Join(stack, " -> "), include)
+			continue
+		}
+
+		if _, err := os.Stat(include); err != nil {
+			f.errorf(location{keys: []string{IncludeKey}}, "%s", err)
+			continue
+		}
+
+		for name, frag := range c.checkFile(include, stack) {
+			frags[name] = frag
+		}
+	}
+
+	if val, ok := doc[FragmentsKey]; ok {
+		dict, ok := val.(map[string]interface{})
+		if !ok {
+			f.errorf(location{keys: []string{FragmentsKey}}, "\"%s\" is not an object: %v", FragmentsKey, val)
+		}
+
+		for name, fragVal := range dict {
+			frag, ok := fragVal.(map[string]interface{})
+			if !ok {
+				f.errorf(location{keys: []string{FragmentsKey, name}, fragment: name}, "fragment is not an object: %v", fragVal)
+				continue
+			}
+
+			frags[name] = frag
+		}
+
+		for name := range dict {
+			if frag, ok := frags[name]; ok {
+				f.checkRules(location{keys: []string{FragmentsKey, name}, fragment: name}, frag, frags)
+			}
+		}
+	}
+
+	for name, val := range doc {
+		if isDirective(name) {
+			if name != IncludeKey && name != FragmentsKey && name != VersionKey {
+				f.warnf(location{keys: []string{name}}, "unknown directive \"%s\"", name)
+			}
+			continue
+		}
+
+		tagDoc, ok := val.(map[string]interface{})
+		if !ok {
+			f.errorf(location{keys: []string{name}, tag: name}, "tag is not an object: %v", val)
+			continue
+		}
+
+		f.checkRules(location{keys: []string{name}, tag: name}, tagDoc, frags)
+	}
+
+	return frags
+}
+
+// checkRules checks the rules of a tag or fragment, and the fragments that it
+// uses.
+func (f *fileChecker) checkRules(loc location, doc map[string]interface{}, frags fragments) {
+	for rType, val := range doc {
+		ruleLoc := loc.child(rType)
+
+		if rType == UseKey {
+			f.checkUses(ruleLoc, val, frags, nil)
+			continue
+		}
+
+		if isDirective(rType) {
+			f.warnf(ruleLoc, "unknown directive \"%s\"", rType)
+			continue
+		}
+
+		known := rules.Keys(rType)
+		if known == nil {
+			f.errorf(ruleLoc, "unknown rule type \"%s\"", rType)
+			continue
+		}
+
+		ruleLoc.rule = rType
+
+		ruleDoc, ok := val.(map[string]interface{})
+		if !ok {
+			f.errorf(ruleLoc, "rule is not an object: %v", val)
+			continue
+		}
+
+		rule, _ := rules.New(rType)
+		if err := rule.Load(ruleDoc); err != nil {
+			msg := strings.TrimSpace(err.Error())
+			f.errorf(ruleLoc, "%s", strings.TrimPrefix(msg, "["+rType+"] "))
+		}
+
+		for key := range ruleDoc {
+			if !contains(known, key) {
+				f.warnf(ruleLoc.child(key), "unknown key \"%s\"", key)
+			}
+		}
+
+		// Undefined variables are only errors in strict mode, but are always
+		// reported since they are usually mistakes.
+		for _, key := range expandedKeys(rType) {
+			item, ok := ruleDoc[key]
+			if !ok {
+				continue
+			}
+
+			if _, err := expandValue(utils.Expander{}, item); err != nil {
+				f.errorf(ruleLoc.child(key), "%s", err)
+			} else if _, err := expandValue(utils.Expander{Strict: true}, item); err != nil {
+				f.report(!Strict, ruleLoc.child(key), "%s", err)
+			}
+		}
+	}
+}
+
+// checkUses checks that the fragments in a "$use" list exist and do not use
+// each other in a cycle.
+func (f *fileChecker) checkUses(loc location, val interface{}, frags fragments, stack []string) {
+	list, ok := val.([]interface{})
+	if !ok {
+		f.errorf(loc, "\"%s\" is not a list: %v", UseKey, val)
+		return
+	}
+
+	for _, item := range list {
+		name, ok := item.(string)
+		if !ok {
+			f.errorf(loc, "invalid value in \"%s\": %v", UseKey, item)
+			continue
+		}
+
+		if contains(stack, name) {
+			f.errorf(loc, "fragment cycle: %s -> %s", strings.Join(stack, " -> "), name)
+			continue
+		}
+
+		frag, ok := frags[name]
+		if !ok {
+			f.errorf(loc, "fragment \"%s\" not found", name)
+			continue
+		}
+
+		if uses, ok := frag[UseKey]; ok {
+			f.checkUses(loc, uses, frags, append(stack, name))
+		}
+	}
+}
+
+// child returns the location of a key inside the value at loc.
+func (loc location) child(key string) location {
+	loc.keys = append(loc.keys[:len(loc.keys):len(loc.keys)], key)
+	return loc
+}
+
+func (f *fileChecker) errorf(loc location, format string, args ...interface{}) {
+	f.report(false, loc, format, args...)
+}
+
+func (f *fileChecker) warnf(loc location, format string, args ...interface{}) {
+	f.report(true, loc, format, args...)
+}
+
+func (f *fileChecker) report(warning bool, loc location, format string, args ...interface{}) {
+	f.problems = append(f.problems, Problem{
+		Path:     f.path,
+		Pos:      f.positions.lookup(loc.keys...),
+		Tag:      loc.tag,
+		Fragment: loc.fragment,
+		Rule:     loc.rule,
+		Message:  fmt.Sprintf(format, args...),
+		Warning:  warning,
+	})
+}
+
+// sort orders the problems by their position in the file.
+func (f *fileChecker) sort() {
+	sort.SliceStable(f.problems, func(i, j int) bool {
+		a, b := f.problems[i], f.problems[j]
+
+		if a.Pos.Line != b.Pos.Line {
+			return a.Pos.Line < b.Pos.Line
+		}
+
+		if a.Pos.Col != b.Pos.Col {
+			return a.Pos.Col < b.Pos.Col
+		}
+
+		return a.String() < b.String()
+	})
+}
+
+// add adds problems to the results, skipping duplicates, such as the same
+// fragment cycle found through multiple tags.
+func (c *checker) add(problems ...Problem) {
+	for _, p := range problems {
+		key := p.String()
+
+		if !c.seen[key] {
+			c.seen[key] = true
+			c.problems = append(c.problems, p)
+		}
+	}
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
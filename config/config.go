@@ -1,11 +1,13 @@
 package config
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
+	"reflect"
+	"sort"
 
 	"github.com/OpenPeeDeeP/xdg"
+	"github.com/mecha/tags/log"
 	"github.com/mecha/tags/rules"
 	"github.com/mecha/tags/tags"
 	"github.com/mecha/tags/utils"
@@ -18,14 +20,14 @@ type (
 )
 
 func DefaultPath() string {
-	defPath := xdg.ConfigHome() + "/tags/rules.json"
+	defPath := findConfigFile(xdg.ConfigHome() + "/tags/rules")
 
 	envPath, ok := os.LookupEnv("TAGS_CONFIG")
 	if !ok {
 		return defPath
 	}
 
-	expPath, err := utils.ExpandTilde(envPath)
+	expPath, err := utils.Expand(envPath)
 	if err != nil {
 		return defPath
 	}
@@ -33,24 +35,70 @@ func DefaultPath() string {
 	return expPath
 }
 
+// findConfigFile returns the path of the first existing config file with the
+// given path and any of the supported extensions, or the JSON path if none
+// exist.
+func findConfigFile(base string) string {
+	for _, f := range formatExts {
+		if _, err := os.Stat(base + f.ext); err == nil {
+			return base + f.ext
+		}
+	}
+
+	return base + ".json"
+}
+
+// Read reads a config file, resolving its includes and rule fragments, and
+// expanding the variables and home directories in its rules' values.
 func Read(path string) (map[string]tags.Tag, error) {
+	result, _, err := readWithOrigins(path)
+	return result, err
+}
+
+// readWithOrigins reads a config file like Read, and also returns the file
+// that defines each rule, which may be an included file.
+func readWithOrigins(path string) (map[string]tags.Tag, Origins, error) {
 	_, err := os.Stat(path)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	resolved, err := resolveFile(path, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfg, err := expandConfig(path, resolved.cfg)
+	if err != nil {
+		return nil, nil, err
 	}
 
+	result, err := tagsFromConfig(path, cfg)
+
+	return result, resolved.origins, err
+}
+
+// ReadFile reads only the tags and rules that are defined directly in a
+// config file, without resolving its includes and rule fragments. This is
+// used to edit a file without copying other files' rules into it. Variables
+// are not expanded, so that they are kept when the file is written.
+func ReadFile(path string) (map[string]tags.Tag, error) {
 	cfg, err := parseFile(path)
 	if err != nil {
 		return nil, err
 	}
 
+	return tagsFromConfig(path, cfg)
+}
+
+func tagsFromConfig(path string, cfg Config) (map[string]tags.Tag, error) {
 	result := make(map[string]tags.Tag, 0)
 
 	for name, tagCfg := range cfg {
 		rules, err := ruleListFromConfig(tagCfg)
 
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("%s: tag \"%s\": %s", path, name, err)
 		}
 
 		result[name] = tags.Tag{Rules: rules}
@@ -59,47 +107,197 @@ func Read(path string) (map[string]tags.Tag, error) {
 	return result, nil
 }
 
-func Write(path string, data map[string]tags.Tag) error {
-	config := Config{}
+// Write writes tags to a config file. Only the tags that changed are written
+// anew: the rest of the file, including its comments, directives and the
+// keys that are not known to the rule types, is kept as it is. The command
+// that made the change is recorded in the file's history.
+func Write(path string, data map[string]tags.Tag, command string) error {
+	unlock, err := Lock(path)
+	if err != nil {
+		return err
+	}
 
-	for name, tag := range data {
-		if len(tag.Rules) == 0 {
-			continue
+	defer unlock()
+
+	f, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+
+	doc := f.doc
+
+	for _, name := range append([]string{}, doc.keys...) {
+		if _, ok := data[name]; !ok && !isDirective(name) {
+			doc.delete(name)
 		}
+	}
 
-		config[name] = make(TagConfig, 0)
+	names := make([]string, 0, len(data))
+	for name := range data {
+		names = append(names, name)
+	}
 
-		for _, rule := range tag.Rules {
-			ruleType := ""
-			switch rule.(type) {
-			case *rules.FileExists:
-				ruleType = "file_exists"
-			case *rules.FileContains:
-				ruleType = "file_contains"
-			case *rules.InPath:
-				ruleType = "in_path"
-			default:
-				return fmt.Errorf("Unknown rule type: %T", rule)
-			}
+	sort.Strings(names)
 
-			ruleCfg := rule.GetConfig()
+	for _, name := range names {
+		tagDoc, ok := doc.get(name).(*orderedMap)
+		if !ok {
+			tagDoc = newOrderedMap()
+		}
 
-			if len(ruleCfg) > 0 {
-				config[name][ruleType] = ruleCfg
-			}
+		if err := updateTagDoc(tagDoc, data[name]); err != nil {
+			return err
+		}
+
+		if tagDoc.len() > 0 {
+			doc.set(name, tagDoc)
+		} else {
+			doc.delete(name)
 		}
 	}
 
-	str, err := json.MarshalIndent(config, "", "  ")
+	f.updateVersion()
+
+	str, err := f.contents()
+	if err != nil {
+		return err
+	}
+
+	return replaceFile(path, str, command)
+}
+
+// Rename renames a tag in a config file. The tag's rules, directives and
+// comments are kept, and so is its position in the file. The command that
+// made the change is recorded in the file's history.
+func Rename(path string, oldName string, newName string, command string) error {
+	unlock, err := Lock(path)
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	f, err := readConfigFile(path)
 	if err != nil {
 		return err
 	}
 
-	err = os.WriteFile(DefaultPath(), str, 0644)
+	if _, ok := f.doc.values[oldName]; !ok || isDirective(oldName) {
+		return fmt.Errorf("Tag \"%s\" not found", oldName)
+	} else if _, ok := f.doc.values[newName]; ok {
+		return fmt.Errorf("Tag \"%s\" already exists", newName)
+	} else if isDirective(newName) {
+		return fmt.Errorf("Invalid tag name: \"%s\"", newName)
+	}
+
+	f.rename(oldName, newName)
+	f.updateVersion()
+
+	str, err := f.contents()
+	if err != nil {
+		return err
+	}
+
+	return replaceFile(path, str, command)
+}
+
+// updateTagDoc updates the rules in a tag's document to match the tag,
+// keeping its directives, the order of its rules and the unknown keys in its
+// rules' configs.
+func updateTagDoc(tagDoc *orderedMap, tag tags.Tag) error {
+	ruleCfgs := make(map[string]map[string]interface{}, len(tag.Rules))
+	ruleTypes := make([]string, 0, len(tag.Rules))
+
+	for _, rule := range tag.Rules {
+		rType := rules.GetType(rule)
+		if rType == "" {
+			return fmt.Errorf("Unknown rule type: %T", rule)
+		}
+
+		if cfg := rule.GetConfig(); len(cfg) > 0 {
+			ruleCfgs[rType] = cfg
+			ruleTypes = append(ruleTypes, rType)
+		}
+	}
+
+	for _, key := range append([]string{}, tagDoc.keys...) {
+		if _, ok := ruleCfgs[key]; !ok && !isDirective(key) {
+			tagDoc.delete(key)
+		}
+	}
+
+	for _, rType := range ruleTypes {
+		cfg := ruleCfgs[rType]
+		known := rules.Keys(rType)
+
+		ruleDoc, ok := tagDoc.get(rType).(*orderedMap)
+		if !ok {
+			ruleDoc = newOrderedMap()
+		} else if unchanged(rType, ruleDoc, cfg) {
+			// Keep the way the values are written, such as sizes.
+			continue
+		}
+
+		for _, key := range append([]string{}, ruleDoc.keys...) {
+			if _, ok := cfg[key]; !ok && contains(known, key) {
+				ruleDoc.delete(key)
+			}
+		}
+
+		// New keys are added in the order in which the rule type lists them.
+		for _, key := range append(known, sortedKeys(cfg)...) {
+			if val, ok := cfg[key]; ok {
+				ruleDoc.set(key, mergeValue(ruleDoc.get(key), plainValue(val)))
+			}
+		}
+
+		tagDoc.set(rType, ruleDoc)
+	}
 
 	return nil
 }
 
+// unchanged reports whether a rule's document yields the given config.
+func unchanged(rType string, ruleDoc *orderedMap, cfg map[string]interface{}) bool {
+	rule, err := rules.New(rType)
+	if err != nil {
+		return false
+	}
+
+	if err = rule.Load(toPlain(ruleDoc).(map[string]interface{})); err != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(plainValue(rule.GetConfig()), plainValue(cfg))
+}
+
+// Convert translates a config file to another format, based on the extension
+// of the destination file.
+func Convert(src string, dst string) error {
+	f, err := readConfigFile(src)
+	if err != nil {
+		return err
+	}
+
+	format, err := FormatOf(dst)
+	if err != nil {
+		return err
+	}
+
+	f.updateVersion()
+
+	str, err := encode(format, f.doc, f.style)
+	if err != nil {
+		return err
+	}
+
+	if hasComments(f.format, f.raw) {
+		log.Error("%s: warning: comments are not converted to %s.\n", src, dst)
+	}
+
+	return replaceFile(dst, str, "config convert")
+}
+
 func ruleListFromConfig(cfg TagConfig) ([]rules.Rule, error) {
 	ruleList := make([]rules.Rule, 0)
 
@@ -116,20 +314,9 @@ func ruleListFromConfig(cfg TagConfig) ([]rules.Rule, error) {
 }
 
 func ruleFromConfig(rType string, cfg RuleConfig) (rules.Rule, error) {
-	var (
-		rule rules.Rule = nil
-		err  error      = nil
-	)
-
-	switch rType {
-	case "file_exists":
-		rule = &rules.FileExists{}
-	case "file_contains":
-		rule = &rules.FileContains{}
-	case "in_path":
-		rule = &rules.InPath{}
-	default:
-		return nil, fmt.Errorf("Unknown rule type: %s", rType)
+	rule, err := rules.New(rType)
+	if err != nil {
+		return nil, err
 	}
 
 	err = rule.Load(cfg)
@@ -141,18 +328,80 @@ func ruleFromConfig(rType string, cfg RuleConfig) (rules.Rule, error) {
 	}
 }
 
-func parseFile(path string) (Config, error) {
+// readDoc reads and decodes a config file, and migrates it to the latest
+// version, without interpreting it.
+func readDoc(path string) (map[string]interface{}, error) {
 	raw, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
-	var result Config
-	err = json.Unmarshal(raw, &result)
+	format, err := FormatOf(path)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := decode(format, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := migrate(doc); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
 
+// parseFile reads the tags defined in a config file, ignoring directives.
+func parseFile(path string) (Config, error) {
+	doc, err := readDoc(path)
 	if err != nil {
 		return nil, err
 	}
 
+	return configFromDoc(doc)
+}
+
+func configFromDoc(doc map[string]interface{}) (Config, error) {
+	result := make(Config, len(doc))
+
+	for name, tagVal := range doc {
+		if isDirective(name) {
+			continue
+		}
+
+		tagDoc, ok := tagVal.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Tag \"%s\" is not an object: %v", name, tagVal)
+		}
+
+		tagCfg, err := tagConfigFromDoc(tagDoc)
+		if err != nil {
+			return nil, fmt.Errorf("Tag \"%s\": %s", name, err)
+		}
+
+		result[name] = tagCfg
+	}
+
+	return result, nil
+}
+
+func tagConfigFromDoc(doc map[string]interface{}) (TagConfig, error) {
+	result := make(TagConfig, len(doc))
+
+	for rType, ruleVal := range doc {
+		if isDirective(rType) {
+			continue
+		}
+
+		ruleDoc, ok := ruleVal.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Rule \"%s\" is not an object: %v", rType, ruleVal)
+		}
+
+		result[rType] = ruleDoc
+	}
+
 	return result, nil
 }
@@ -0,0 +1,387 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+
+	"github.com/mecha/tags/log"
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// configFile is a config file that is read to be changed. Changes are made
+	// to doc, and written back by changing only the entries of the file that
+	// differ from orig.
+	configFile struct {
+		path   string
+		format Format
+		raw    []byte
+		style  fileStyle
+
+		// orig is the file's document as it is written, and doc is the
+		// document migrated to the latest version.
+		orig *orderedMap
+		doc  *orderedMap
+
+		// from is the version that the file was at.
+		from int
+
+		// renames are the top-level keys that were renamed in doc, as pairs
+		// of the old and the new key.
+		renames [][2]string
+	}
+
+	// docEditor changes the entries of a config file's contents in place,
+	// keeping the other entries, their formatting and their comments as they
+	// are. Paths have one key for the top-level entries, such as tags, and
+	// two for the entries inside them, such as rules.
+	docEditor interface {
+		// editable reports whether the entries inside a top-level entry can be
+		// changed one by one. If not, the whole entry is replaced instead.
+		editable(key string) bool
+
+		// set replaces the value of an entry, or adds the entry at the end of
+		// its parent if it does not exist.
+		set(path []string, val interface{}) error
+
+		// prepend adds a top-level entry at the start of the document.
+		prepend(key string, val interface{}) error
+
+		// delete removes an entry, along with the comments above it.
+		delete(path []string) error
+
+		// rename changes the key of a top-level entry, keeping its value and
+		// position.
+		rename(key string, newKey string) error
+
+		// lostComments returns the paths of the entries that were replaced
+		// along with the comments inside them, joined with dots.
+		lostComments() []string
+
+		// bytes returns the changed contents.
+		bytes() ([]byte, error)
+	}
+)
+
+// readConfigFile reads a config file to change it. A missing file yields an
+// empty document.
+func readConfigFile(path string) (*configFile, error) {
+	format, err := FormatOf(path)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	orig, err := parseOrdered(format, raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	doc, from, err := migrateOrdered(orig)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	return &configFile{
+		path:   path,
+		format: format,
+		raw:    raw,
+		style:  detectStyle(raw),
+		orig:   orig,
+		doc:    doc,
+		from:   from,
+	}, nil
+}
+
+// contents returns the file's changed contents. Only the entries that were
+// changed, added or removed are written anew, so that the rest of the file,
+// including its comments, is kept byte for byte.
+func (f *configFile) contents() ([]byte, error) {
+	if len(bytes.TrimSpace(f.raw)) == 0 {
+		return encode(f.format, f.doc, f.style)
+	}
+
+	// Entries are added on lines of their own, so the last line must end with
+	// a newline while the file is changed.
+	raw := f.raw
+	if !f.style.newline {
+		raw = append(append([]byte{}, raw...), '\n')
+	}
+
+	ed, err := newEditor(f.format, raw, f.style)
+	if err != nil {
+		// The layout of the file is not supported, such as a YAML document
+		// that is written as a single flow mapping.
+		if hasComments(f.format, f.raw) {
+			log.Error("%s: warning: the file's comments are removed, since it is written anew.\n", f.path)
+		}
+
+		return encode(f.format, f.doc, f.style)
+	}
+
+	// Renamed keys are changed in place, and then compared under their new
+	// names.
+	orig := f.orig
+	for _, names := range f.renames {
+		if err := ed.rename(names[0], names[1]); err != nil {
+			return nil, err
+		}
+
+		orig = orig.clone()
+		orig.rename(names[0], names[1])
+	}
+
+	if err := diffEntries(ed, orig, f.doc); err != nil {
+		return nil, err
+	}
+
+	for _, key := range ed.lostComments() {
+		log.Error("%s: warning: the comments inside \"%s\" are removed, since it is written anew.\n", f.path, key)
+	}
+
+	result, err := ed.bytes()
+	if err != nil || f.style.newline {
+		return result, err
+	}
+
+	return bytes.TrimSuffix(result, []byte("\n")), nil
+}
+
+// rename renames a top-level key, keeping its value and its position.
+func (f *configFile) rename(key string, newKey string) {
+	f.doc.rename(key, newKey)
+	f.renames = append(f.renames, [2]string{key, newKey})
+}
+
+// updateVersion sets the document's version to the latest one, if the file
+// already has a version, or if the document needs one to be read correctly.
+// Otherwise, the version is left out, so that it is not added to every file
+// that is changed.
+func (f *configFile) updateVersion() {
+	latest := json.Number(strconv.Itoa(LatestVersion()))
+
+	if _, ok := f.orig.values[VersionKey]; ok || f.needsVersion() {
+		f.doc.set(VersionKey, latest)
+	} else {
+		f.doc.delete(VersionKey)
+	}
+}
+
+// needsVersion reports whether the document needs a version: when migrations
+// changed the file's contents, or when reading the document as a file without
+// a version would change it.
+func (f *configFile) needsVersion() bool {
+	withoutVersion := func(doc *orderedMap) map[string]interface{} {
+		plain := toPlain(doc).(map[string]interface{})
+		delete(plain, VersionKey)
+		return plain
+	}
+
+	doc := withoutVersion(f.doc)
+
+	if f.from < LatestVersion() {
+		migrated, _, err := migrateOrdered(f.orig)
+		if err != nil || !reflect.DeepEqual(withoutVersion(migrated), withoutVersion(f.orig)) {
+			return true
+		}
+	}
+
+	asOld := withoutVersion(f.doc)
+	if _, err := migrate(asOld); err != nil {
+		return true
+	}
+
+	delete(asOld, VersionKey)
+
+	return !reflect.DeepEqual(asOld, doc)
+}
+
+// newEditor returns an editor for the contents of a config file.
+func newEditor(format Format, raw []byte, style fileStyle) (docEditor, error) {
+	switch format {
+	case JSON, JSONC:
+		return newJSONEditor(raw, style)
+	case YAML:
+		return newYAMLEditor(raw, style)
+	case TOML:
+		return newTOMLEditor(raw)
+	default:
+		return nil, fmt.Errorf("Unknown config file format: %s", format)
+	}
+}
+
+// hasComments reports whether the contents of a config file have comments.
+func hasComments(format Format, raw []byte) bool {
+	switch format {
+	case JSON, JSONC:
+		return hasJSONComment(raw)
+	case YAML:
+		var doc yaml.Node
+		return yaml.Unmarshal(raw, &doc) == nil && hasYAMLComment(&doc)
+	case TOML:
+		return hasTOMLComment(raw)
+	default:
+		return false
+	}
+}
+
+// diffEntries makes the changes to an editor that turn the before document
+// into the after document. The keys that both documents have must be in the
+// same order, and new keys are added at the end, except for the version,
+// which is added at the start.
+func diffEntries(ed docEditor, before *orderedMap, after *orderedMap) error {
+	for _, key := range before.keys {
+		if _, ok := after.values[key]; !ok {
+			if err := ed.delete([]string{key}); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, key := range after.keys {
+		oldVal, existed := before.values[key]
+		newVal := after.values[key]
+
+		oldMap, oldIsMap := oldVal.(*orderedMap)
+		newMap, newIsMap := newVal.(*orderedMap)
+
+		var err error
+
+		switch {
+		case !existed && key == VersionKey:
+			err = ed.prepend(key, newVal)
+		case !existed:
+			err = ed.set([]string{key}, newVal)
+		case sameValue(oldVal, newVal):
+			continue
+		case oldIsMap && newIsMap && newMap.len() > 0 && ed.editable(key):
+			err = diffInside(ed, key, oldMap, newMap)
+		default:
+			err = ed.set([]string{key}, newVal)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// diffInside makes the changes to the entries inside a top-level entry.
+func diffInside(ed docEditor, key string, before *orderedMap, after *orderedMap) error {
+	for _, sub := range before.keys {
+		if _, ok := after.values[sub]; !ok {
+			if err := ed.delete([]string{key, sub}); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, sub := range after.keys {
+		if oldVal, ok := before.values[sub]; ok && sameValue(oldVal, after.values[sub]) {
+			continue
+		}
+
+		if err := ed.set([]string{key, sub}, after.values[sub]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sameValue reports whether two ordered values are equal, regardless of the
+// order of their keys and the way their numbers are written.
+func sameValue(a interface{}, b interface{}) bool {
+	return reflect.DeepEqual(toPlain(a), toPlain(b))
+}
+
+type (
+	// edit replaces the bytes from start to end with text. Edits with the
+	// same start and end insert text.
+	edit struct {
+		start, end int
+		text       string
+	}
+)
+
+// applyEdits applies edits to raw, which may only overlap if they remove
+// text. Insertions are applied before the other edits at the same position,
+// and in the order in which they were made.
+func applyEdits(raw []byte, edits []edit) []byte {
+	sorted := make([]edit, len(edits))
+	copy(sorted, edits)
+
+	before := func(a, b edit) bool {
+		return a.start < b.start || (a.start == b.start && a.start == a.end && b.start != b.end)
+	}
+
+	// A stable insertion sort keeps the order of equal insertions.
+	for i := 1; i < len(sorted); i++ {
+		for j := i; j > 0 && before(sorted[j], sorted[j-1]); j-- {
+			sorted[j], sorted[j-1] = sorted[j-1], sorted[j]
+		}
+	}
+
+	buf := bytes.Buffer{}
+	pos := 0
+
+	for _, e := range sorted {
+		// Removed ranges may overlap at the blank lines around them.
+		if e.start < pos {
+			if e.end <= pos || e.text != "" {
+				continue
+			}
+			e.start = pos
+		}
+
+		buf.Write(raw[pos:e.start])
+		buf.WriteString(e.text)
+		pos = e.end
+	}
+
+	buf.Write(raw[pos:])
+
+	return buf.Bytes()
+}
+
+// lineStart returns the offset of the start of the line that contains pos.
+func lineStart(raw []byte, pos int) int {
+	return bytes.LastIndexByte(raw[:pos], '\n') + 1
+}
+
+// lineEnd returns the offset of the start of the line after the one that
+// contains pos, or the length of raw if it is the last line.
+func lineEnd(raw []byte, pos int) int {
+	if i := bytes.IndexByte(raw[pos:], '\n'); i >= 0 {
+		return pos + i + 1
+	}
+
+	return len(raw)
+}
+
+// isBlank reports whether a byte slice has only whitespace.
+func isBlank(b []byte) bool {
+	return len(bytes.TrimSpace(b)) == 0
+}
+
+// indentOf returns the leading spaces and tabs of the line that contains pos.
+func indentOf(raw []byte, pos int) string {
+	start := lineStart(raw, pos)
+	end := start
+
+	for end < len(raw) && (raw[end] == ' ' || raw[end] == '\t') {
+		end++
+	}
+
+	return string(raw[start:end])
+}
@@ -0,0 +1,659 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type (
+	// jsonEditor changes JSON and JSONC files. Objects whose entries are each
+	// on their own lines are changed line by line, keeping the comments and
+	// blank lines around their entries. Other objects are rewritten on one
+	// line, keeping the separators between their entries.
+	jsonEditor struct {
+		raw   []byte
+		style fileStyle
+		root  *jsonObject
+
+		// lost are the entries whose comments were removed.
+		lost []string
+	}
+
+	jsonObject struct {
+		open, close int
+		members     []*jsonMember
+
+		// multiline is set if the entries are each on their own lines, and
+		// the braces are on lines of their own.
+		multiline bool
+		// trailingComma is set if the last entry is followed by a comma.
+		trailingComma bool
+	}
+
+	jsonMember struct {
+		key string
+
+		// The positions of the key and the value, the comma after the value,
+		// or -1, and the lines of the entry, including the comments and blank
+		// lines above it.
+		keyStart, keyEnd   int
+		valStart, valEnd   int
+		comma              int
+		lineStart, lineEnd int
+		object             *jsonObject
+
+		// Changes to the entry. Added entries have no position.
+		added   bool
+		deleted bool
+		newKey  string
+		newVal  string
+		changed bool
+	}
+
+	jsonScanner struct {
+		raw []byte
+		pos int
+	}
+)
+
+func newJSONEditor(raw []byte, style fileStyle) (*jsonEditor, error) {
+	s := &jsonScanner{raw: raw}
+	s.skipSpace()
+
+	if s.pos >= len(raw) || raw[s.pos] != '{' {
+		return nil, fmt.Errorf("The config is not an object")
+	}
+
+	root, err := s.object()
+	if err != nil {
+		return nil, err
+	}
+
+	if s.skipSpace(); s.pos < len(raw) {
+		return nil, fmt.Errorf("Unexpected %q after the config object", raw[s.pos])
+	}
+
+	return &jsonEditor{raw: raw, style: style, root: root}, nil
+}
+
+// skipSpace skips whitespace and comments.
+func (s *jsonScanner) skipSpace() {
+	for s.pos < len(s.raw) {
+		switch {
+		case bytes.IndexByte([]byte(" \t\r\n"), s.raw[s.pos]) >= 0:
+			s.pos++
+		case bytes.HasPrefix(s.raw[s.pos:], []byte("//")):
+			s.pos = lineEnd(s.raw, s.pos)
+		case bytes.HasPrefix(s.raw[s.pos:], []byte("/*")):
+			end := bytes.Index(s.raw[s.pos+2:], []byte("*/"))
+			if end < 0 {
+				s.pos = len(s.raw)
+			} else {
+				s.pos += end + 4
+			}
+		default:
+			return
+		}
+	}
+}
+
+// value skips a value, returning the object if it is one.
+func (s *jsonScanner) value() (*jsonObject, error) {
+	if s.pos >= len(s.raw) {
+		return nil, fmt.Errorf("Unexpected end of the config")
+	}
+
+	switch s.raw[s.pos] {
+	case '{':
+		return s.object()
+	case '[':
+		s.pos++
+
+		for s.skipSpace(); s.pos < len(s.raw) && s.raw[s.pos] != ']'; s.skipSpace() {
+			if _, err := s.value(); err != nil {
+				return nil, err
+			}
+
+			if s.skipSpace(); s.pos < len(s.raw) && s.raw[s.pos] == ',' {
+				s.pos++
+			}
+		}
+
+		if s.pos >= len(s.raw) {
+			return nil, fmt.Errorf("Unexpected end of the config")
+		}
+
+		s.pos++
+
+		return nil, nil
+	case '"':
+		return nil, s.str()
+	default:
+		start := s.pos
+		for s.pos < len(s.raw) && bytes.IndexByte([]byte(",:{}[] \t\r\n/"), s.raw[s.pos]) < 0 {
+			s.pos++
+		}
+
+		if s.pos == start {
+			return nil, fmt.Errorf("Unexpected %q in the config", s.raw[s.pos])
+		}
+
+		return nil, nil
+	}
+}
+
+// hasJSONComment reports whether JSON text has a comment.
+func hasJSONComment(raw []byte) bool {
+	s := &jsonScanner{raw: raw}
+
+	for s.pos < len(raw) {
+		switch {
+		case raw[s.pos] == '"':
+			s.str()
+		case bytes.HasPrefix(raw[s.pos:], []byte("//")) || bytes.HasPrefix(raw[s.pos:], []byte("/*")):
+			return true
+		default:
+			s.pos++
+		}
+	}
+
+	return false
+}
+
+func (s *jsonScanner) str() error {
+	for s.pos++; s.pos < len(s.raw); s.pos++ {
+		switch s.raw[s.pos] {
+		case '\\':
+			s.pos++
+		case '"':
+			s.pos++
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Unexpected end of the config")
+}
+
+func (s *jsonScanner) object() (*jsonObject, error) {
+	obj := &jsonObject{open: s.pos}
+	s.pos++
+
+	for {
+		s.skipSpace()
+
+		if s.pos >= len(s.raw) {
+			return nil, fmt.Errorf("Unexpected end of the config")
+		} else if s.raw[s.pos] == '}' {
+			obj.close = s.pos
+			s.pos++
+			break
+		} else if s.raw[s.pos] != '"' {
+			return nil, fmt.Errorf("Unexpected %q in the config", s.raw[s.pos])
+		}
+
+		m := &jsonMember{keyStart: s.pos, comma: -1}
+		if err := s.str(); err != nil {
+			return nil, err
+		}
+
+		m.keyEnd = s.pos
+		if err := json.Unmarshal(s.raw[m.keyStart:m.keyEnd], &m.key); err != nil {
+			return nil, err
+		}
+
+		if s.skipSpace(); s.pos >= len(s.raw) || s.raw[s.pos] != ':' {
+			return nil, fmt.Errorf("Expected \":\" after %s", s.raw[m.keyStart:m.keyEnd])
+		}
+
+		s.pos++
+		s.skipSpace()
+
+		m.valStart = s.pos
+
+		var err error
+		if m.object, err = s.value(); err != nil {
+			return nil, err
+		}
+
+		m.valEnd = s.pos
+
+		if s.skipSpace(); s.pos < len(s.raw) && s.raw[s.pos] == ',' {
+			m.comma = s.pos
+			s.pos++
+		}
+
+		obj.members = append(obj.members, m)
+	}
+
+	obj.layout(s.raw)
+
+	return obj, nil
+}
+
+// layout finds whether an object's entries are each on their own lines, and
+// if so, the lines of each entry.
+func (obj *jsonObject) layout(raw []byte) {
+	if len(obj.members) == 0 {
+		return
+	}
+
+	last := obj.members[len(obj.members)-1]
+	obj.trailingComma = last.comma >= 0
+
+	// restOfLine reports whether the rest of a line has only whitespace or a
+	// comment.
+	restOfLine := func(pos int) bool {
+		rest := bytes.TrimSpace(raw[pos:lineEnd(raw, pos)])
+		return len(rest) == 0 || bytes.HasPrefix(rest, []byte("//"))
+	}
+
+	if !restOfLine(obj.open+1) || !isBlank(raw[lineStart(raw, obj.close):obj.close]) {
+		return
+	}
+
+	for _, m := range obj.members {
+		after := m.valEnd
+		if m.comma >= 0 {
+			if bytes.IndexByte(raw[m.valEnd:m.comma], '\n') >= 0 {
+				return
+			}
+			after = m.comma + 1
+		}
+
+		if !isBlank(raw[lineStart(raw, m.keyStart):m.keyStart]) || !restOfLine(after) {
+			return
+		}
+	}
+
+	obj.multiline = true
+	start := lineEnd(raw, obj.open)
+
+	for _, m := range obj.members {
+		after := m.valEnd
+		if m.comma >= 0 {
+			after = m.comma + 1
+		}
+
+		m.lineStart, m.lineEnd = start, lineEnd(raw, after)
+		start = m.lineEnd
+	}
+}
+
+func (obj *jsonObject) find(key string) *jsonMember {
+	for _, m := range obj.members {
+		if m.key == key && !m.deleted {
+			return m
+		}
+	}
+
+	return nil
+}
+
+func (e *jsonEditor) lookup(path []string) (*jsonObject, *jsonMember, error) {
+	obj := e.root
+
+	for _, key := range path[:len(path)-1] {
+		m := obj.find(key)
+		if m == nil || m.object == nil || m.changed {
+			return nil, nil, fmt.Errorf("\"%s\" is not an object", key)
+		}
+
+		obj = m.object
+	}
+
+	return obj, obj.find(path[len(path)-1]), nil
+}
+
+func (e *jsonEditor) editable(key string) bool {
+	m := e.root.find(key)
+	return m != nil && m.object != nil && !m.changed
+}
+
+func (e *jsonEditor) set(path []string, val interface{}) error {
+	obj, m, err := e.lookup(path)
+	if err != nil {
+		return err
+	}
+
+	if m == nil {
+		text, err := e.encodeValue(val, obj.compact(), e.childIndent(obj))
+		if err != nil {
+			return err
+		}
+
+		// The inside of an empty object is replaced by its new entries.
+		if len(obj.original()) == 0 && hasJSONComment(e.raw[obj.open:obj.close]) {
+			e.lost = append(e.lost, strings.Join(path[:len(path)-1], "."))
+		}
+
+		obj.members = append(obj.members, &jsonMember{key: path[len(path)-1], added: true, newVal: text, changed: true})
+
+		return nil
+	}
+
+	compact, indent := obj.compact(), e.childIndent(obj)
+	if !m.added {
+		compact = bytes.IndexByte(e.raw[m.valStart:m.valEnd], '\n') < 0
+		indent = indentOf(e.raw, m.keyStart)
+	}
+
+	if m.newVal, err = e.encodeValue(val, compact, indent); err != nil {
+		return err
+	}
+
+	if !m.added && hasJSONComment(e.raw[m.valStart:m.valEnd]) {
+		e.lost = append(e.lost, strings.Join(path, "."))
+	}
+
+	m.changed = true
+
+	return nil
+}
+
+func (e *jsonEditor) prepend(key string, val interface{}) error {
+	text, err := e.encodeValue(val, e.root.compact(), e.childIndent(e.root))
+	if err != nil {
+		return err
+	}
+
+	e.root.members = append([]*jsonMember{{key: key, added: true, newVal: text, changed: true}}, e.root.members...)
+
+	return nil
+}
+
+func (e *jsonEditor) delete(path []string) error {
+	_, m, err := e.lookup(path)
+	if err != nil {
+		return err
+	} else if m == nil {
+		return fmt.Errorf("No such key: %s", strings.Join(path, "."))
+	}
+
+	m.deleted = true
+
+	return nil
+}
+
+func (e *jsonEditor) rename(key string, newKey string) error {
+	m := e.root.find(key)
+	if m == nil {
+		return fmt.Errorf("No such key: %s", key)
+	}
+
+	m.key = newKey
+	m.newKey = newKey
+
+	return nil
+}
+
+func (e *jsonEditor) lostComments() []string {
+	return e.lost
+}
+
+func (e *jsonEditor) bytes() ([]byte, error) {
+	buf := bytes.Buffer{}
+
+	buf.Write(e.raw[:e.root.open])
+
+	if err := e.writeObject(&buf, e.root); err != nil {
+		return nil, err
+	}
+
+	buf.Write(e.raw[e.root.close+1:])
+
+	return buf.Bytes(), nil
+}
+
+// compact reports whether an object is written on one line, in which case
+// the values that are added to it are too.
+func (obj *jsonObject) compact() bool {
+	return len(obj.original()) > 0 && !obj.multiline
+}
+
+// original returns the entries that are in the file.
+func (obj *jsonObject) original() []*jsonMember {
+	result := make([]*jsonMember, 0, len(obj.members))
+	for _, m := range obj.members {
+		if !m.added {
+			result = append(result, m)
+		}
+	}
+
+	return result
+}
+
+// childIndent returns the indentation of the entries of an object.
+func (e *jsonEditor) childIndent(obj *jsonObject) string {
+	for _, m := range obj.members {
+		if !m.added {
+			return indentOf(e.raw, m.keyStart)
+		}
+	}
+
+	return indentOf(e.raw, obj.open) + e.style.indent
+}
+
+// encodeValue encodes a value on one line, or on several lines whose
+// nested lines are indented from the given indentation.
+func (e *jsonEditor) encodeValue(val interface{}, compact bool, indent string) (string, error) {
+	buf := bytes.Buffer{}
+
+	if compact {
+		if err := writeCompactJSON(&buf, val); err != nil {
+			return "", err
+		}
+
+		return buf.String(), nil
+	}
+
+	if err := writeJSON(&buf, val, e.style.indent, 0); err != nil {
+		return "", err
+	}
+
+	return strings.ReplaceAll(buf.String(), "\n", "\n"+indent), nil
+}
+
+func (e *jsonEditor) keyText(m *jsonMember) (string, error) {
+	if m.added || m.newKey != "" {
+		str, err := marshalJSON(m.key)
+		return string(str), err
+	}
+
+	return string(e.raw[m.keyStart:m.keyEnd]), nil
+}
+
+func (e *jsonEditor) writeValue(buf *bytes.Buffer, m *jsonMember) error {
+	switch {
+	case m.changed:
+		buf.WriteString(m.newVal)
+	case m.object != nil:
+		return e.writeObject(buf, m.object)
+	default:
+		buf.Write(e.raw[m.valStart:m.valEnd])
+	}
+
+	return nil
+}
+
+func (e *jsonEditor) writeObject(buf *bytes.Buffer, obj *jsonObject) error {
+	live := make([]*jsonMember, 0, len(obj.members))
+	for _, m := range obj.members {
+		if !m.deleted {
+			live = append(live, m)
+		}
+	}
+
+	original := obj.original()
+
+	var first, last *jsonMember
+	if len(original) > 0 {
+		first, last = original[0], original[len(original)-1]
+	}
+
+	switch {
+	case last == nil && len(live) == 0:
+		buf.Write(e.raw[obj.open : obj.close+1])
+	case last == nil:
+		// An empty object is written with its new entries on their own lines.
+		indent := e.childIndent(obj)
+		buf.WriteString("{\n")
+
+		for i, m := range live {
+			if err := e.writeAdded(buf, m, indent, i < len(live)-1); err != nil {
+				return err
+			}
+		}
+
+		buf.WriteString(indentOf(e.raw, obj.open) + "}")
+	case obj.multiline:
+		indent := e.childIndent(obj)
+		buf.Write(e.raw[obj.open:lineEnd(e.raw, obj.open)])
+
+		for i, m := range live {
+			comma := i < len(live)-1 || obj.trailingComma
+
+			if m.added {
+				if err := e.writeAdded(buf, m, indent, comma); err != nil {
+					return err
+				}
+				continue
+			}
+
+			key, err := e.keyText(m)
+			if err != nil {
+				return err
+			}
+
+			buf.Write(e.raw[m.lineStart:m.keyStart])
+			buf.WriteString(key)
+			buf.Write(e.raw[m.keyEnd:m.valStart])
+
+			if err := e.writeValue(buf, m); err != nil {
+				return err
+			}
+
+			rest := m.valEnd
+			if m.comma >= 0 {
+				buf.Write(e.raw[m.valEnd:m.comma])
+				rest = m.comma + 1
+			}
+
+			if comma {
+				buf.WriteString(",")
+			}
+
+			buf.Write(e.raw[rest:m.lineEnd])
+		}
+
+		buf.Write(e.raw[last.lineEnd : obj.close+1])
+	default:
+		sep := ", "
+		if len(original) > 1 {
+			sep = string(e.raw[first.valEnd:original[1].keyStart])
+		}
+
+		// A trailing comma is kept if the last entry is still the last one.
+		trail := last.valEnd
+		if last.comma >= 0 && (len(live) == 0 || live[len(live)-1] != last) {
+			trail = last.comma + 1
+		}
+
+		buf.Write(e.raw[obj.open:first.keyStart])
+
+		for i, m := range live {
+			if i > 0 {
+				buf.WriteString(sep)
+			}
+
+			key, err := e.keyText(m)
+			if err != nil {
+				return err
+			}
+
+			buf.WriteString(key)
+
+			if m.added {
+				buf.WriteString(": ")
+			} else {
+				buf.Write(e.raw[m.keyEnd:m.valStart])
+			}
+
+			if err := e.writeValue(buf, m); err != nil {
+				return err
+			}
+		}
+
+		buf.Write(e.raw[trail : obj.close+1])
+	}
+
+	return nil
+}
+
+// writeAdded writes a new entry on a line of its own.
+func (e *jsonEditor) writeAdded(buf *bytes.Buffer, m *jsonMember, indent string, comma bool) error {
+	key, err := e.keyText(m)
+	if err != nil {
+		return err
+	}
+
+	buf.WriteString(indent + key + ": " + m.newVal)
+	if comma {
+		buf.WriteString(",")
+	}
+	buf.WriteString("\n")
+
+	return nil
+}
+
+// writeCompactJSON writes a value on one line.
+func writeCompactJSON(buf *bytes.Buffer, val interface{}) error {
+	switch val := val.(type) {
+	case *orderedMap:
+		buf.WriteString("{")
+
+		for i, key := range val.keys {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+
+			str, err := marshalJSON(key)
+			if err != nil {
+				return err
+			}
+
+			buf.Write(str)
+			buf.WriteString(": ")
+
+			if err := writeCompactJSON(buf, val.values[key]); err != nil {
+				return err
+			}
+		}
+
+		buf.WriteString("}")
+	case []interface{}:
+		buf.WriteString("[")
+
+		for i, item := range val {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+
+			if err := writeCompactJSON(buf, item); err != nil {
+				return err
+			}
+		}
+
+		buf.WriteString("]")
+	default:
+		str, err := marshalJSON(val)
+		if err != nil {
+			return err
+		}
+
+		buf.Write(str)
+	}
+
+	return nil
+}
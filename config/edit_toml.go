@@ -0,0 +1,610 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type (
+	// tomlEditor changes TOML files by their statements. A rule may be
+	// written as a "[tag.rule]" table, as dotted keys such as
+	// "tag.rule.key = value", or as a pair in a "[tag]" table, such as
+	// "rule.key = value" or "rule = { ... }". A changed rule is written anew
+	// in the same way, and a new rule is written like the last rule of its
+	// tag. Tags that are written as a single pair, such as an inline table,
+	// are replaced as a whole.
+	tomlEditor struct {
+		raw    []byte
+		blocks []*tomlBlock
+		edits  []edit
+
+		// changed are the top-level entries that were replaced, which cannot
+		// be changed further.
+		changed map[string]bool
+
+		// lost are the entries whose comments were removed.
+		lost []string
+	}
+
+	// tomlBlock is a table header and the key/value pairs under it, or a
+	// key/value pair, along with the comments right above it.
+	tomlBlock struct {
+		start, stmtStart, end int
+		header                bool
+
+		// path is the path of a header, or the full path of a pair's key,
+		// starting with the path of the table that the pair is in.
+		path []string
+
+		// table is the header that a pair is under, and body are the pairs
+		// under a header.
+		table *tomlBlock
+		body  []*tomlBlock
+
+		// The position of the first segment of the header or key, which is
+		// the tag's name for headers and for pairs before the first header.
+		nameStart, nameEnd int
+	}
+
+	tomlScanner struct {
+		raw []byte
+		pos int
+	}
+)
+
+func newTOMLEditor(raw []byte) (*tomlEditor, error) {
+	e := &tomlEditor{raw: raw, changed: make(map[string]bool)}
+	s := &tomlScanner{raw: raw}
+
+	var (
+		current  *tomlBlock
+		comments = -1
+	)
+
+	for s.pos < len(raw) {
+		start := s.pos
+		s.skipSpace()
+
+		switch {
+		case s.pos >= len(raw) || raw[s.pos] == '\n' || raw[s.pos] == '\r':
+			// Comments in a table's body that are not right above the next
+			// header belong to the table.
+			if current != nil && comments >= 0 {
+				current.end = start
+			}
+
+			s.pos = lineEnd(raw, start)
+			comments = -1
+		case raw[s.pos] == '#':
+			s.pos = lineEnd(raw, start)
+			if comments < 0 {
+				comments = start
+			}
+		case raw[s.pos] == '[':
+			s.pos++
+			if s.pos < len(raw) && raw[s.pos] == '[' {
+				s.pos++
+			}
+
+			path, spans, err := s.key()
+			if err != nil {
+				return nil, err
+			}
+
+			if s.pos >= len(raw) || raw[s.pos] != ']' {
+				return nil, fmt.Errorf("Expected \"]\" after table header")
+			}
+
+			s.pos = lineEnd(raw, s.pos)
+
+			current = &tomlBlock{start: start, stmtStart: start, end: s.pos, path: path, header: true, nameStart: spans[0][0], nameEnd: spans[0][1]}
+			if comments >= 0 {
+				current.start = comments
+			}
+
+			e.blocks = append(e.blocks, current)
+			comments = -1
+		default:
+			path, spans, err := s.key()
+			if err != nil {
+				return nil, err
+			}
+
+			if s.pos >= len(raw) || raw[s.pos] != '=' {
+				return nil, fmt.Errorf("Expected \"=\" after key")
+			}
+
+			s.pos = s.value(s.pos + 1)
+
+			pair := &tomlBlock{start: start, stmtStart: start, end: s.pos, path: path, nameStart: spans[0][0], nameEnd: spans[0][1]}
+			if comments >= 0 {
+				pair.start = comments
+			}
+
+			if current == nil {
+				e.blocks = append(e.blocks, pair)
+			} else {
+				pair.path = append(append([]string{}, current.path...), path...)
+				pair.table = current
+				current.body = append(current.body, pair)
+				current.end = s.pos
+			}
+
+			comments = -1
+		}
+	}
+
+	if current != nil && comments >= 0 {
+		current.end = len(raw)
+	}
+
+	return e, nil
+}
+
+func (s *tomlScanner) skipSpace() {
+	for s.pos < len(s.raw) && (s.raw[s.pos] == ' ' || s.raw[s.pos] == '\t') {
+		s.pos++
+	}
+}
+
+// key reads a dotted key, returning its segments and their positions.
+func (s *tomlScanner) key() ([]string, [][2]int, error) {
+	path := make([]string, 0)
+	spans := make([][2]int, 0)
+
+	for {
+		s.skipSpace()
+		start := s.pos
+
+		if s.pos >= len(s.raw) {
+			return nil, nil, fmt.Errorf("Unexpected end of the config")
+		}
+
+		var segment string
+
+		switch s.raw[s.pos] {
+		case '"':
+			for s.pos++; s.pos < len(s.raw) && s.raw[s.pos] != '"' && s.raw[s.pos] != '\n'; s.pos++ {
+				if s.raw[s.pos] == '\\' {
+					s.pos++
+				}
+			}
+
+			s.pos++
+			if err := json.Unmarshal(s.raw[start:s.pos], &segment); err != nil {
+				return nil, nil, fmt.Errorf("Invalid key %s", s.raw[start:s.pos])
+			}
+		case '\'':
+			end := bytes.IndexByte(s.raw[s.pos+1:], '\'')
+			if end < 0 {
+				return nil, nil, fmt.Errorf("Unexpected end of the config")
+			}
+
+			segment = string(s.raw[s.pos+1 : s.pos+1+end])
+			s.pos += end + 2
+		default:
+			for s.pos < len(s.raw) && bareTOMLKey.Match(s.raw[s.pos:s.pos+1]) {
+				s.pos++
+			}
+
+			if s.pos == start {
+				return nil, nil, fmt.Errorf("Unexpected %q in the config", s.raw[s.pos])
+			}
+
+			segment = string(s.raw[start:s.pos])
+		}
+
+		path = append(path, segment)
+		spans = append(spans, [2]int{start, s.pos})
+
+		if s.skipSpace(); s.pos >= len(s.raw) || s.raw[s.pos] != '.' {
+			return path, spans, nil
+		}
+
+		s.pos++
+	}
+}
+
+// value skips a value, which may span several lines, and the rest of its
+// last line. It returns the position of the next line.
+func (s *tomlScanner) value(pos int) int {
+	depth := 0
+
+	for pos < len(s.raw) {
+		if end := s.str(pos); end > pos {
+			pos = end
+			continue
+		}
+
+		switch c := s.raw[pos]; {
+		case c == '#':
+			pos = lineEnd(s.raw, pos) - 1
+		case c == '[' || c == '{':
+			depth++
+			pos++
+		case c == ']' || c == '}':
+			depth--
+			pos++
+		case c == '\n' && depth <= 0:
+			return pos + 1
+		default:
+			pos++
+		}
+	}
+
+	return len(s.raw)
+}
+
+// str skips a string, if one starts at pos, and returns the position after
+// it.
+func (s *tomlScanner) str(pos int) int {
+	switch {
+	case bytes.HasPrefix(s.raw[pos:], []byte(`"""`)) || bytes.HasPrefix(s.raw[pos:], []byte(`'''`)):
+		quote := s.raw[pos : pos+3]
+
+		for pos += 3; pos < len(s.raw) && !bytes.HasPrefix(s.raw[pos:], quote); pos++ {
+			if quote[0] == '"' && s.raw[pos] == '\\' {
+				pos++
+			}
+		}
+
+		// Strings may end with up to two more quotes.
+		for pos += 3; pos < len(s.raw) && s.raw[pos] == quote[0]; pos++ {
+		}
+	case s.raw[pos] == '"':
+		for pos++; pos < len(s.raw) && s.raw[pos] != '"' && s.raw[pos] != '\n'; pos++ {
+			if s.raw[pos] == '\\' {
+				pos++
+			}
+		}
+		pos++
+	case s.raw[pos] == '\'':
+		for pos++; pos < len(s.raw) && s.raw[pos] != '\'' && s.raw[pos] != '\n'; pos++ {
+		}
+		pos++
+	}
+
+	return min(pos, len(s.raw))
+}
+
+// hasTOMLComment reports whether TOML text has a comment.
+func hasTOMLComment(raw []byte) bool {
+	s := &tomlScanner{raw: raw}
+
+	for pos := 0; pos < len(raw); pos++ {
+		if end := s.str(pos); end > pos {
+			pos = end - 1
+		} else if raw[pos] == '#' {
+			return true
+		}
+	}
+
+	return false
+}
+
+// owned returns the blocks of a tag, or of one of its rules if a rule is
+// given: the headers and the pairs before the first header whose paths start
+// with the path, and the pairs with such paths in the tables above it.
+func (e *tomlEditor) owned(path []string) []*tomlBlock {
+	result := make([]*tomlBlock, 0)
+
+	for _, block := range e.blocks {
+		switch {
+		case hasPathPrefix(block.path, path):
+			result = append(result, block)
+		case block.header && hasPathPrefix(path, block.path):
+			for _, pair := range block.body {
+				if hasPathPrefix(pair.path, path) {
+					result = append(result, pair)
+				}
+			}
+		}
+	}
+
+	return result
+}
+
+// hasPathPrefix reports whether a path starts with the keys of a prefix.
+func hasPathPrefix(path []string, prefix []string) bool {
+	if len(path) < len(prefix) {
+		return false
+	}
+
+	for i, key := range prefix {
+		if path[i] != key {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (e *tomlEditor) editable(key string) bool {
+	blocks := e.owned([]string{key})
+	if len(blocks) == 0 || e.changed[key] {
+		return false
+	}
+
+	for _, block := range blocks {
+		if !block.header && len(block.path) < 2 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (e *tomlEditor) set(path []string, val interface{}) error {
+	if len(path) == 1 {
+		e.changed[path[0]] = true
+	}
+
+	blocks := e.owned(path)
+
+	if len(blocks) > 0 {
+		text, err := e.encodeLike(blocks[0], path, val)
+		if err != nil {
+			return err
+		}
+
+		e.edits = append(e.edits, edit{blocks[0].stmtStart, blocks[0].end, text})
+		e.remove(blocks[1:])
+
+		for i, block := range blocks {
+			start := block.start
+			if i == 0 {
+				start = block.stmtStart
+			}
+
+			if hasTOMLComment(e.raw[start:block.end]) {
+				e.lost = append(e.lost, strings.Join(path, "."))
+				break
+			}
+		}
+
+		return nil
+	}
+
+	if _, ok := val.(*orderedMap); !ok && len(path) == 1 {
+		// Values that are not tables must come before the first header.
+		return e.prepend(path[0], val)
+	}
+
+	if len(path) == 1 {
+		return e.addTag(path[0], val)
+	}
+
+	return e.addRule(path, val)
+}
+
+// addTag adds a tag at the end of the file, written like the last tag: as
+// pairs with dotted keys, as a "[tag]" table with pairs, or as tables for
+// each rule.
+func (e *tomlEditor) addTag(key string, val interface{}) error {
+	var last *tomlBlock
+	for _, block := range e.blocks {
+		if !isDirective(block.path[0]) {
+			last = block
+		}
+	}
+
+	var (
+		text string
+		err  error
+	)
+
+	switch {
+	case last != nil && !last.header:
+		if text, err = e.pairs([]string{key}, val, len(last.path)-1); last.start > 0 && isBlank(e.raw[lineStart(e.raw, last.start-1):last.start]) {
+			text = "\n" + text
+		}
+	case last != nil && len(last.path) == 1 && len(last.body) > 0:
+		pair := last.body[len(last.body)-1]
+		if text, err = e.pairs(nil, val, len(pair.path)-1); err == nil {
+			text = "\n[" + tomlKey(key) + "]\n" + text
+		}
+	default:
+		text, err = e.encode([]string{key}, val)
+		text = "\n" + text
+	}
+
+	if err != nil {
+		return err
+	}
+
+	e.edits = append(e.edits, edit{len(e.raw), len(e.raw), text})
+
+	return nil
+}
+
+// addRule adds a rule after the last rule of its tag, written like it.
+func (e *tomlEditor) addRule(path []string, val interface{}) error {
+	tagBlocks := e.owned(path[:1])
+	if len(tagBlocks) == 0 {
+		return fmt.Errorf("No such key: %s", path[0])
+	}
+
+	last := tagBlocks[len(tagBlocks)-1]
+
+	var (
+		pos  int
+		text string
+		err  error
+	)
+
+	switch {
+	case last.header && len(last.path) == 1:
+		// The rule is added to the end of the "[tag]" table.
+		depth := 1
+		pos = lineEnd(e.raw, last.stmtStart)
+
+		if len(last.body) > 0 {
+			pair := last.body[len(last.body)-1]
+			pos, depth = pair.end, len(pair.path)-len(path)
+		}
+
+		text, err = e.pairs(path[1:], val, depth)
+	case !last.header:
+		pos = last.end
+		text, err = e.pairs(path, val, max(len(last.path)-len(path), 0))
+	default:
+		pos = last.end
+		text, err = e.encode(path, val)
+		text = "\n" + text
+	}
+
+	if err != nil {
+		return err
+	}
+
+	e.edits = append(e.edits, edit{pos, pos, text})
+
+	return nil
+}
+
+func (e *tomlEditor) prepend(key string, val interface{}) error {
+	text, err := e.pairs([]string{key}, val, 0)
+	if err != nil {
+		return err
+	}
+
+	if len(e.blocks) == 0 {
+		e.edits = append(e.edits, edit{len(e.raw), len(e.raw), text})
+		return nil
+	}
+
+	if e.blocks[0].header {
+		text += "\n"
+	}
+
+	e.edits = append(e.edits, edit{e.blocks[0].start, e.blocks[0].start, text})
+
+	return nil
+}
+
+func (e *tomlEditor) delete(path []string) error {
+	blocks := e.owned(path)
+	if len(blocks) == 0 {
+		return fmt.Errorf("No such key: %s", strings.Join(path, "."))
+	}
+
+	e.remove(blocks)
+
+	return nil
+}
+
+func (e *tomlEditor) rename(key string, newKey string) error {
+	blocks := e.owned([]string{key})
+	if len(blocks) == 0 {
+		return fmt.Errorf("No such key: %s", key)
+	}
+
+	for _, block := range blocks {
+		e.edits = append(e.edits, edit{block.nameStart, block.nameEnd, tomlKey(newKey)})
+	}
+
+	return nil
+}
+
+func (e *tomlEditor) lostComments() []string {
+	return e.lost
+}
+
+func (e *tomlEditor) bytes() ([]byte, error) {
+	return applyEdits(e.raw, e.edits), nil
+}
+
+// remove removes blocks, along with the blank lines that separate them from
+// the blocks before them, or after them if they are at the start of the file.
+func (e *tomlEditor) remove(blocks []*tomlBlock) {
+	for _, block := range blocks {
+		start, end := block.start, block.end
+
+		if block == e.blocks[0] {
+			for end < len(e.raw) && isBlank(e.raw[end:lineEnd(e.raw, end)]) {
+				end = lineEnd(e.raw, end)
+			}
+		} else {
+			for start > 0 && isBlank(e.raw[lineStart(e.raw, start-1):start]) {
+				start = lineStart(e.raw, start-1)
+			}
+		}
+
+		e.edits = append(e.edits, edit{start, end, ""})
+	}
+}
+
+// encodeLike encodes a value at a path in the same way as the block that
+// holds it: as tables if the block is a header, or as pairs with keys as deep
+// as the block's key.
+func (e *tomlEditor) encodeLike(block *tomlBlock, path []string, val interface{}) (string, error) {
+	if block.header {
+		return e.encode(path, val)
+	}
+
+	keys := path
+	if block.table != nil {
+		keys = path[len(block.table.path):]
+	}
+
+	return e.pairs(keys, val, len(block.path)-len(path))
+}
+
+// encode encodes a value at a path as tables.
+func (e *tomlEditor) encode(path []string, val interface{}) (string, error) {
+	doc := newOrderedMap()
+	table := doc
+
+	for _, key := range path[:len(path)-1] {
+		next := newOrderedMap()
+		table.set(key, next)
+		table = next
+	}
+
+	table.set(path[len(path)-1], val)
+
+	buf := bytes.Buffer{}
+	if err := writeTOML(&buf, doc, nil); err != nil {
+		return "", err
+	}
+
+	return strings.TrimLeft(buf.String(), "\n"), nil
+}
+
+// pairs encodes a value as key/value pairs. Tables are split into a pair for
+// each of their keys, with dotted keys, up to the given depth, and deeper
+// tables are written inline.
+func (e *tomlEditor) pairs(keys []string, val interface{}, depth int) (string, error) {
+	if table, ok := val.(*orderedMap); ok && depth > 0 && table.len() > 0 {
+		sb := strings.Builder{}
+
+		for _, key := range table.keys {
+			if table.values[key] == nil {
+				continue
+			}
+
+			str, err := e.pairs(append(keys[:len(keys):len(keys)], key), table.values[key], depth-1)
+			if err != nil {
+				return "", err
+			}
+
+			sb.WriteString(str)
+		}
+
+		return sb.String(), nil
+	}
+
+	str, err := tomlValue(val)
+	if err != nil {
+		return "", err
+	}
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = tomlKey(key)
+	}
+
+	return strings.Join(parts, ".") + " = " + str + "\n", nil
+}
@@ -0,0 +1,371 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// yamlEditor changes YAML files whose top level is a block mapping, line
+	// by line. Entries are replaced with their new values, written at the
+	// indentation of the entries around them, and the comments above them are
+	// kept.
+	yamlEditor struct {
+		raw   []byte
+		style fileStyle
+		lines []int
+		root  *yamlMapping
+		edits []edit
+
+		// changed are the top-level entries that were replaced, which cannot
+		// be changed further.
+		changed map[string]bool
+
+		// lost are the entries whose comments were removed.
+		lost []string
+	}
+
+	// yamlMapping is a block mapping whose keys are at the start of their
+	// lines, in the same column.
+	yamlMapping struct {
+		node    *yaml.Node
+		column  int
+		entries []*yamlEntry
+	}
+
+	yamlEntry struct {
+		key, value *yaml.Node
+
+		// The offsets of the entry's first line, including the comments
+		// above it, of its key's line and of the end of its last line.
+		start, keyLine, end int
+	}
+)
+
+func newYAMLEditor(raw []byte, style fileStyle) (*yamlEditor, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil, fmt.Errorf("The config is empty")
+	}
+
+	e := &yamlEditor{raw: raw, style: style, lines: []int{0}, changed: make(map[string]bool)}
+
+	for i, c := range raw {
+		if c == '\n' {
+			e.lines = append(e.lines, i+1)
+		}
+	}
+
+	root, ok := e.mapping(doc.Content[0])
+	if !ok || root.column != 1 {
+		return nil, fmt.Errorf("The config is not a block mapping")
+	}
+
+	e.root = root
+
+	return e, nil
+}
+
+// offset returns the offset of a line and column, which start at 1.
+func (e *yamlEditor) offset(line int, column int) int {
+	if line > len(e.lines) {
+		return len(e.raw)
+	}
+
+	return e.lines[line-1] + column - 1
+}
+
+// mapping finds the lines of the entries of a block mapping, if the mapping
+// has entries and each of them starts its own line.
+func (e *yamlEditor) mapping(node *yaml.Node) (*yamlMapping, bool) {
+	if node.Kind != yaml.MappingNode || node.Style&yaml.FlowStyle != 0 || len(node.Content) == 0 {
+		return nil, false
+	}
+
+	m := &yamlMapping{node: node, column: node.Content[0].Column}
+	indent := m.column - 1
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		keyLine := e.offset(key.Line, 1)
+
+		if key.Column != m.column || !isBlank(e.raw[keyLine:e.offset(key.Line, key.Column)]) {
+			return nil, false
+		}
+
+		entry := &yamlEntry{key: key, value: value, start: keyLine, keyLine: keyLine}
+
+		// Comment lines right above the key, at its column, belong to it.
+		for line := key.Line - 1; line >= 1; line-- {
+			text := e.raw[e.offset(line, 1):e.offset(line+1, 1)]
+			trimmed := bytes.TrimLeft(text, " ")
+
+			if len(text)-len(trimmed) != indent || !bytes.HasPrefix(trimmed, []byte("#")) {
+				break
+			}
+
+			entry.start = e.offset(line, 1)
+		}
+
+		// The entry ends at its last line that is indented further than its
+		// key, or that is an item of a list at the key's column.
+		entry.end = e.offset(key.Line+1, 1)
+
+		for line := key.Line + 1; line <= len(e.lines); line++ {
+			text := e.raw[e.offset(line, 1):e.offset(line+1, 1)]
+			trimmed := bytes.TrimLeft(text, " ")
+
+			if len(bytes.TrimSpace(trimmed)) == 0 {
+				continue
+			}
+
+			item := value.Kind == yaml.SequenceNode && len(trimmed) > 0 && trimmed[0] == '-' &&
+				(len(trimmed) == 1 || bytes.IndexByte([]byte(" \t\r\n"), trimmed[1]) >= 0)
+
+			if len(text)-len(trimmed) < indent || (len(text)-len(trimmed) == indent && !item) {
+				break
+			}
+
+			entry.end = e.offset(line+1, 1)
+		}
+
+		m.entries = append(m.entries, entry)
+	}
+
+	return m, true
+}
+
+func (m *yamlMapping) find(key string) *yamlEntry {
+	for _, entry := range m.entries {
+		if entry.key.Value == key {
+			return entry
+		}
+	}
+
+	return nil
+}
+
+func (e *yamlEditor) tagMapping(key string) (*yamlMapping, bool) {
+	entry := e.root.find(key)
+	if entry == nil || e.changed[key] {
+		return nil, false
+	}
+
+	return e.mapping(entry.value)
+}
+
+func (e *yamlEditor) editable(key string) bool {
+	_, ok := e.tagMapping(key)
+	return ok
+}
+
+func (e *yamlEditor) set(path []string, val interface{}) error {
+	m := e.root
+	if len(path) > 1 {
+		var ok bool
+		if m, ok = e.tagMapping(path[0]); !ok {
+			return fmt.Errorf("\"%s\" is not a block mapping", path[0])
+		}
+	} else {
+		e.changed[path[0]] = true
+	}
+
+	key := path[len(path)-1]
+
+	entry := m.find(key)
+	if entry == nil {
+		last := m.entries[len(m.entries)-1]
+
+		text, err := e.encodeEntry(key, val, m.column-1, nil)
+		if err != nil {
+			return err
+		}
+
+		e.edits = append(e.edits, edit{last.end, last.end, e.separator(m) + text})
+
+		return nil
+	}
+
+	text, err := e.encodeEntry(key, val, m.column-1, entry.value)
+	if err != nil {
+		return err
+	}
+
+	// The key is written anew too, so that its comment and the value on its
+	// line are replaced along with the rest of the value.
+	e.edits = append(e.edits, edit{entry.keyLine, entry.end, text})
+
+	if entry.key.LineComment != "" || entry.key.FootComment != "" || hasYAMLComment(entry.value) {
+		e.lost = append(e.lost, strings.Join(path, "."))
+	}
+
+	return nil
+}
+
+func (e *yamlEditor) prepend(key string, val interface{}) error {
+	text, err := e.encodeEntry(key, val, 0, nil)
+	if err != nil {
+		return err
+	}
+
+	e.edits = append(e.edits, edit{e.root.entries[0].start, e.root.entries[0].start, text + e.separator(e.root)})
+
+	return nil
+}
+
+func (e *yamlEditor) delete(path []string) error {
+	m := e.root
+	if len(path) > 1 {
+		var ok bool
+		if m, ok = e.tagMapping(path[0]); !ok {
+			return fmt.Errorf("\"%s\" is not a block mapping", path[0])
+		}
+	}
+
+	for i, entry := range m.entries {
+		if entry.key.Value != path[len(path)-1] {
+			continue
+		}
+
+		start, end := entry.start, entry.end
+
+		// The blank lines that separate the entry from the others are removed
+		// too: the ones above it, or below it if it is the first entry.
+		if i == 0 {
+			for end < len(e.raw) && isBlank(e.raw[end:lineEnd(e.raw, end)]) && lineEnd(e.raw, end) > end {
+				end = lineEnd(e.raw, end)
+			}
+		} else {
+			for start > 0 && isBlank(e.raw[lineStart(e.raw, start-1):start]) {
+				start = lineStart(e.raw, start-1)
+			}
+		}
+
+		e.edits = append(e.edits, edit{start, end, ""})
+
+		return nil
+	}
+
+	return fmt.Errorf("No such key: %s", strings.Join(path, "."))
+}
+
+func (e *yamlEditor) rename(key string, newKey string) error {
+	entry := e.root.find(key)
+	if entry == nil {
+		return fmt.Errorf("No such key: %s", key)
+	}
+
+	start := e.offset(entry.key.Line, entry.key.Column)
+	end := start + len(entry.key.Value)
+
+	switch e.raw[start] {
+	case '"':
+		for end = start + 1; end < len(e.raw) && e.raw[end] != '"'; end++ {
+			if e.raw[end] == '\\' {
+				end++
+			}
+		}
+		end++
+	case '\'':
+		for end = start + 1; end < len(e.raw); end++ {
+			if e.raw[end] == '\'' {
+				if end+1 < len(e.raw) && e.raw[end+1] == '\'' {
+					end++
+					continue
+				}
+				break
+			}
+		}
+		end++
+	}
+
+	text, err := e.encodeEntry(newKey, nil, 0, nil)
+	if err != nil {
+		return err
+	}
+
+	// The encoded entry is "key: null".
+	text = strings.TrimSuffix(strings.TrimRight(text, "\n"), ": null")
+	e.edits = append(e.edits, edit{start, end, text})
+
+	return nil
+}
+
+func (e *yamlEditor) lostComments() []string {
+	return e.lost
+}
+
+func (e *yamlEditor) bytes() ([]byte, error) {
+	return applyEdits(e.raw, e.edits), nil
+}
+
+// separator returns a blank line if the entries of a mapping are separated
+// by blank lines.
+func (e *yamlEditor) separator(m *yamlMapping) string {
+	if len(m.entries) > 1 && isBlank(e.raw[lineStart(e.raw, m.entries[1].start-1):m.entries[1].start]) {
+		return "\n"
+	}
+
+	return ""
+}
+
+// encodeEntry encodes a key and its value as the lines of a mapping entry,
+// at the given indentation. If the entry replaces a value that is written in
+// the flow style, such as "[a, b]", so is the new value.
+func (e *yamlEditor) encodeEntry(key string, val interface{}, indent int, old *yaml.Node) (string, error) {
+	valNode := yamlNode(val)
+	if old != nil && old.Style&yaml.FlowStyle != 0 {
+		valNode.Style = yaml.FlowStyle
+	}
+
+	node := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{yamlNode(key), valNode}}
+
+	width := len(e.style.indent)
+	if strings.Contains(e.style.indent, "\t") || width < 2 {
+		width = 2
+	}
+
+	buf := bytes.Buffer{}
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(width)
+
+	if err := enc.Encode(node); err != nil {
+		return "", err
+	}
+
+	enc.Close()
+
+	lines := strings.SplitAfter(buf.String(), "\n")
+	prefix := strings.Repeat(" ", indent)
+
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			lines[i] = prefix + line
+		}
+	}
+
+	return strings.Join(lines, ""), nil
+}
+
+// hasYAMLComment reports whether a node or any of the nodes inside it has a
+// comment.
+func hasYAMLComment(node *yaml.Node) bool {
+	if node.HeadComment != "" || node.LineComment != "" || node.FootComment != "" {
+		return true
+	}
+
+	for _, child := range node.Content {
+		if hasYAMLComment(child) {
+			return true
+		}
+	}
+
+	return false
+}
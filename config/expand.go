@@ -0,0 +1,120 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/mecha/tags/rules"
+	"github.com/mecha/tags/utils"
+)
+
+// Strict makes undefined variables in rule values and include paths an
+// error, instead of expanding them to an empty string.
+var Strict bool
+
+func expander() utils.Expander {
+	return utils.Expander{Strict: Strict}
+}
+
+// expandConfig expands variables and home directories in the values of the
+// rule keys that hold paths, or in their keys for objects that map paths to
+// values. Other keys and values, such as search text and the values of env
+// rules, are kept as is.
+func expandConfig(path string, cfg Config) (Config, error) {
+	result := make(Config, len(cfg))
+
+	for name, tagCfg := range cfg {
+		result[name] = make(TagConfig, len(tagCfg))
+
+		for rType, ruleCfg := range tagCfg {
+			expanded, err := expandRule(expander(), rType, ruleCfg)
+			if err != nil {
+				return nil, fmt.Errorf("%s: tag \"%s\": [%s] %s", path, name, rType, err)
+			}
+
+			result[name][rType] = expanded
+		}
+	}
+
+	return result, nil
+}
+
+// expandRule returns a copy of a rule's config, with the values of its path
+// keys expanded.
+func expandRule(e utils.Expander, rType string, cfg RuleConfig) (RuleConfig, error) {
+	result := make(RuleConfig, len(cfg))
+	for key, val := range cfg {
+		result[key] = val
+	}
+
+	for _, key := range expandedKeys(rType) {
+		val, ok := cfg[key]
+		if !ok {
+			continue
+		}
+
+		expanded, err := expandValue(e, val)
+		if err != nil {
+			return nil, fmt.Errorf("\"%s\": %s", key, err)
+		}
+
+		result[key] = expanded
+	}
+
+	return result, nil
+}
+
+// expandedKeys returns the keys of a rule type's config whose values are
+// expanded.
+func expandedKeys(rType string) []string {
+	keys := make([]string, 0)
+
+	for _, field := range rules.Fields(rType) {
+		if field.Expand {
+			keys = append(keys, field.Name)
+		}
+	}
+
+	return keys
+}
+
+// expandValue expands a string, the strings in a list, or the keys of an
+// object. Values of other types are returned as is, and are reported when the
+// rule is loaded.
+func expandValue(e utils.Expander, val interface{}) (interface{}, error) {
+	switch val := val.(type) {
+	case string:
+		return e.Expand(val)
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(val))
+
+		for key, item := range val {
+			expanded, err := e.Expand(key)
+			if err != nil {
+				return nil, err
+			}
+
+			if _, ok := result[expanded]; ok {
+				return nil, fmt.Errorf("More than one key expands to \"%s\"", expanded)
+			}
+
+			result[expanded] = item
+		}
+
+		return result, nil
+	case []interface{}:
+		result := make([]interface{}, len(val))
+
+		for i, item := range val {
+			expanded, err := expandValue(e, item)
+			if err != nil {
+				return nil, err
+			}
+
+			result[i] = expanded
+		}
+
+		return result, nil
+	default:
+		return val, nil
+	}
+}
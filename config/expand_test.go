@@ -0,0 +1,80 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mecha/tags/utils"
+)
+
+func TestExpandRule(t *testing.T) {
+	e := utils.Expander{
+		LookupEnv: func(key string) (string, bool) {
+			if key == "SRC" {
+				return "/src", true
+			}
+			return "", false
+		},
+	}
+
+	tests := []struct {
+		rType   string
+		cfg     RuleConfig
+		want    RuleConfig
+		wantErr bool
+	}{
+		{
+			rType: "path_match",
+			cfg:   RuleConfig{"names": []interface{}{"$SRC"}, "paths": []interface{}{"$SRC/*", "re:^$SRC/.*$"}},
+			want:  RuleConfig{"names": []interface{}{"$SRC"}, "paths": []interface{}{"/src/*", "re:^/src/.*$"}},
+		},
+		{
+			rType: "file_contains",
+			cfg:   RuleConfig{"search": map[string]interface{}{"$SRC/go.mod": "$SRC"}},
+			want:  RuleConfig{"search": map[string]interface{}{"/src/go.mod": "$SRC"}},
+		},
+		{
+			rType: "file_type",
+			cfg:   RuleConfig{"types": map[string]interface{}{"${SRC}/*.sh": "shell"}},
+			want:  RuleConfig{"types": map[string]interface{}{"/src/*.sh": "shell"}},
+		},
+		{
+			rType: "count",
+			cfg:   RuleConfig{"glob": "$SRC/**/*.go", "min": 1.0},
+			want:  RuleConfig{"glob": "/src/**/*.go", "min": 1.0},
+		},
+		{
+			rType: "languages",
+			cfg:   RuleConfig{"languages": []interface{}{"Go"}, "ignore": []interface{}{"$SRC/vendor"}},
+			want:  RuleConfig{"languages": []interface{}{"Go"}, "ignore": []interface{}{"/src/vendor"}},
+		},
+		{
+			rType: "env",
+			cfg:   RuleConfig{"equals": map[string]interface{}{"GOPATH": "$SRC"}},
+			want:  RuleConfig{"equals": map[string]interface{}{"GOPATH": "$SRC"}},
+		},
+		{
+			rType:   "file_contains",
+			cfg:     RuleConfig{"search": map[string]interface{}{"$SRC/a": "x", "/src/a": "y"}},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.rType, func(t *testing.T) {
+			got, err := expandRule(e, test.rType, test.cfg)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("expandRule() did not fail")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("expandRule() error: %s", err)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expandRule() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,427 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	Format string
+)
+
+const (
+	JSON  Format = "json"
+	JSONC Format = "jsonc"
+	YAML  Format = "yaml"
+	TOML  Format = "toml"
+)
+
+// formatExts maps file extensions to formats, in the order in which config
+// files are looked up.
+var formatExts = []struct {
+	ext    string
+	format Format
+}{
+	{".json", JSON},
+	{".jsonc", JSONC},
+	{".yaml", YAML},
+	{".yml", YAML},
+	{".toml", TOML},
+}
+
+// FormatOf returns the format of a config file, based on its extension.
+func FormatOf(path string) (Format, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+
+	for _, f := range formatExts {
+		if f.ext == ext {
+			return f.format, nil
+		}
+	}
+
+	return "", fmt.Errorf("Unknown config file format: \"%s\". Expected one of .json, .jsonc, .yaml, .yml or .toml", ext)
+}
+
+// decode parses the contents of a config file. Documents in all formats are
+// normalized to the types produced by encoding/json, so that rules can load
+// their config regardless of the format.
+func decode(format Format, raw []byte) (map[string]interface{}, error) {
+	var (
+		doc interface{}
+		err error
+	)
+
+	switch format {
+	case JSON, JSONC:
+		err = json.Unmarshal(StripJSONComments(raw), &doc)
+	case YAML:
+		err = yaml.Unmarshal(raw, &doc)
+	case TOML:
+		doc = make(map[string]interface{})
+		err = toml.Unmarshal(raw, &doc)
+	default:
+		err = fmt.Errorf("Unknown config file format: %s", format)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if format != JSON && format != JSONC {
+		if doc, err = normalize(doc); err != nil {
+			return nil, err
+		}
+	}
+
+	if doc == nil {
+		return map[string]interface{}{}, nil
+	}
+
+	result, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("The config is not an object")
+	}
+
+	return result, nil
+}
+
+// normalize converts a decoded document to the types used by encoding/json,
+// such as float64 for all numbers, by round-tripping it through JSON.
+func normalize(doc interface{}) (interface{}, error) {
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var result interface{}
+	err = json.Unmarshal(raw, &result)
+
+	return result, err
+}
+
+// encode serializes a config document in the given format and style.
+func encode(format Format, doc *orderedMap, style fileStyle) ([]byte, error) {
+	buf := bytes.Buffer{}
+
+	switch format {
+	case JSON, JSONC:
+		if err := writeJSON(&buf, doc, style.indent, 0); err != nil {
+			return nil, err
+		}
+	case YAML:
+		indent := len(style.indent)
+		if strings.Contains(style.indent, "\t") || indent < 2 {
+			indent = 2
+		}
+
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(indent)
+
+		if err := enc.Encode(yamlNode(doc)); err != nil {
+			return nil, err
+		}
+
+		enc.Close()
+	case TOML:
+		if err := writeTOML(&buf, doc, nil); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("Unknown config file format: %s", format)
+	}
+
+	// An empty document is encoded as empty, not nil, contents.
+	result := append([]byte{}, bytes.Trim(buf.Bytes(), "\n")...)
+	if style.newline {
+		result = append(result, '\n')
+	}
+
+	return result, nil
+}
+
+// marshalJSON encodes a value as JSON, without escaping HTML characters.
+func marshalJSON(val interface{}) ([]byte, error) {
+	buf := bytes.Buffer{}
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(val); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+func writeJSON(buf *bytes.Buffer, val interface{}, indent string, depth int) error {
+	inner := strings.Repeat(indent, depth+1)
+
+	switch val := val.(type) {
+	case *orderedMap:
+		if val.len() == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+
+		buf.WriteString("{\n")
+
+		for i, key := range val.keys {
+			str, err := marshalJSON(key)
+			if err != nil {
+				return err
+			}
+
+			buf.WriteString(inner)
+			buf.Write(str)
+			buf.WriteString(": ")
+
+			if err = writeJSON(buf, val.values[key], indent, depth+1); err != nil {
+				return err
+			}
+
+			if i < val.len()-1 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n")
+		}
+
+		buf.WriteString(strings.Repeat(indent, depth) + "}")
+	case []interface{}:
+		if len(val) == 0 {
+			buf.WriteString("[]")
+			return nil
+		}
+
+		buf.WriteString("[\n")
+
+		for i, item := range val {
+			buf.WriteString(inner)
+
+			if err := writeJSON(buf, item, indent, depth+1); err != nil {
+				return err
+			}
+
+			if i < len(val)-1 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n")
+		}
+
+		buf.WriteString(strings.Repeat(indent, depth) + "]")
+	default:
+		str, err := marshalJSON(val)
+		if err != nil {
+			return err
+		}
+
+		buf.Write(str)
+	}
+
+	return nil
+}
+
+func yamlNode(val interface{}) *yaml.Node {
+	switch val := val.(type) {
+	case *orderedMap:
+		node := &yaml.Node{Kind: yaml.MappingNode}
+
+		for _, key := range val.keys {
+			node.Content = append(node.Content, yamlNode(key), yamlNode(val.values[key]))
+		}
+
+		return node
+	case []interface{}:
+		node := &yaml.Node{Kind: yaml.SequenceNode}
+
+		for _, item := range val {
+			node.Content = append(node.Content, yamlNode(item))
+		}
+
+		return node
+	case json.Number:
+		tag := "!!int"
+		if strings.ContainsAny(string(val), ".eE") {
+			tag = "!!float"
+		}
+
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: string(val)}
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(val)}
+	case nil:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: fmt.Sprint(val)}
+	}
+}
+
+var bareTOMLKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// writeTOML writes a table's values, followed by its sub-tables. Objects
+// inside lists are written as inline tables.
+func writeTOML(buf *bytes.Buffer, table *orderedMap, path []string) error {
+	tables := make([]string, 0)
+
+	for _, key := range table.keys {
+		val := table.values[key]
+
+		if _, ok := val.(*orderedMap); ok {
+			tables = append(tables, key)
+			continue
+		} else if val == nil {
+			continue
+		}
+
+		str, err := tomlValue(val)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(buf, "%s = %s\n", tomlKey(key), str)
+	}
+
+	for _, key := range tables {
+		sub := table.values[key].(*orderedMap)
+		subPath := append(path[:len(path):len(path)], key)
+
+		if sub.len() == 0 || hasTOMLValues(sub) {
+			parts := make([]string, len(subPath))
+			for i, part := range subPath {
+				parts[i] = tomlKey(part)
+			}
+
+			fmt.Fprintf(buf, "\n[%s]\n", strings.Join(parts, "."))
+		}
+
+		if err := writeTOML(buf, sub, subPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// hasTOMLValues reports whether a table has values other than sub-tables, and
+// thus needs a header.
+func hasTOMLValues(table *orderedMap) bool {
+	for _, val := range table.values {
+		if _, ok := val.(*orderedMap); !ok && val != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func tomlKey(key string) string {
+	if bareTOMLKey.MatchString(key) {
+		return key
+	}
+
+	str, _ := marshalJSON(key)
+
+	return string(str)
+}
+
+func tomlValue(val interface{}) (string, error) {
+	switch val := val.(type) {
+	case *orderedMap:
+		parts := make([]string, 0, val.len())
+
+		for _, key := range val.keys {
+			str, err := tomlValue(val.values[key])
+			if err != nil {
+				return "", err
+			}
+
+			parts = append(parts, tomlKey(key)+" = "+str)
+		}
+
+		return "{ " + strings.Join(parts, ", ") + " }", nil
+	case []interface{}:
+		parts := make([]string, 0, len(val))
+
+		for _, item := range val {
+			str, err := tomlValue(item)
+			if err != nil {
+				return "", err
+			}
+
+			parts = append(parts, str)
+		}
+
+		return "[" + strings.Join(parts, ", ") + "]", nil
+	case nil:
+		return "", fmt.Errorf("TOML does not support null values")
+	default:
+		// JSON strings are valid TOML basic strings, and JSON numbers and
+		// booleans are valid TOML values.
+		str, err := marshalJSON(val)
+		return string(str), err
+	}
+}
+
+// StripJSONComments removes "//" and "/* */" comments and trailing commas
+// from JSON text. Removed characters are replaced with spaces, and newlines
+// are kept, so that positions in the result match the original text.
+func StripJSONComments(raw []byte) []byte {
+	out := make([]byte, len(raw))
+	copy(out, raw)
+
+	blank := func(from, to int) {
+		for i := from; i < to; i++ {
+			if out[i] != '\n' {
+				out[i] = ' '
+			}
+		}
+	}
+
+	// The index of the last comma that may be a trailing comma, or -1.
+	lastComma := -1
+
+	for i := 0; i < len(out); i++ {
+		switch c := out[i]; {
+		case c == '"':
+			lastComma = -1
+			for i++; i < len(out) && out[i] != '"'; i++ {
+				if out[i] == '\\' {
+					i++
+				}
+			}
+		case c == '/' && i+1 < len(out) && out[i+1] == '/':
+			end := bytes.IndexByte(out[i:], '\n')
+			if end < 0 {
+				end = len(out) - i
+			}
+			blank(i, i+end)
+			i += end - 1
+		case c == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := bytes.Index(out[i+2:], []byte("*/"))
+			if end < 0 {
+				end = len(out) - i - 2
+			} else {
+				end += 2
+			}
+			blank(i, i+2+end)
+			i += 2 + end - 1
+		case c == ',':
+			lastComma = i
+		case c == '}' || c == ']':
+			if lastComma >= 0 {
+				out[lastComma] = ' '
+			}
+			lastComma = -1
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+		default:
+			lastComma = -1
+		}
+	}
+
+	return out
+}
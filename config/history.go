@@ -0,0 +1,132 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// MaxHistory is the number of changes that are kept in a config file's
+// history.
+const MaxHistory = 100
+
+type (
+	// Change is a recorded change to a config file. Before and After hold the
+	// whole contents of the file. Created is set if the file did not exist
+	// before the change, and Removed if the change removed it, since an empty
+	// file has empty contents too.
+	Change struct {
+		Time    time.Time `json:"time"`
+		Command string    `json:"command"`
+		Before  string    `json:"before"`
+		After   string    `json:"after"`
+		Created bool      `json:"created,omitempty"`
+		Removed bool      `json:"removed,omitempty"`
+	}
+)
+
+// HistoryPath returns the path of the history log of a config file, which is
+// kept next to it.
+func HistoryPath(path string) string {
+	return path + ".history"
+}
+
+// ReadHistory reads the recorded changes to a config file, oldest first. A
+// missing history log yields no changes.
+func ReadHistory(path string) ([]Change, error) {
+	raw, err := os.ReadFile(HistoryPath(path))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	result := make([]Change, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	scanner.Buffer(nil, len(raw)+1)
+
+	for n := 1; scanner.Scan(); n++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		change := Change{}
+		if err := json.Unmarshal(scanner.Bytes(), &change); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", HistoryPath(path), n, err)
+		}
+
+		result = append(result, change)
+	}
+
+	return result, scanner.Err()
+}
+
+// recordChange adds a change to a config file's history, dropping the oldest
+// changes beyond MaxHistory.
+func recordChange(path string, change Change) error {
+	history, err := ReadHistory(path)
+	if err != nil {
+		return err
+	}
+
+	history = append(history, change)
+	if len(history) > MaxHistory {
+		history = history[len(history)-MaxHistory:]
+	}
+
+	buf := bytes.Buffer{}
+	enc := json.NewEncoder(&buf)
+
+	for _, c := range history {
+		if err := enc.Encode(c); err != nil {
+			return err
+		}
+	}
+
+	return writeFile(HistoryPath(path), buf.Bytes(), 0644)
+}
+
+// Undo reverts the last n changes to a config file, by restoring the file's
+// contents from before the nth most recent change. The undo is recorded as a
+// change too, so that it can be undone. It fails if the file was changed
+// since the last recorded change, such as by hand.
+func Undo(path string, n int) (Change, error) {
+	unlock, err := Lock(path)
+	if err != nil {
+		return Change{}, err
+	}
+
+	defer unlock()
+
+	history, err := ReadHistory(path)
+	if err != nil {
+		return Change{}, err
+	}
+
+	if n < 1 || n > len(history) {
+		return Change{}, fmt.Errorf("Cannot undo %d changes: the history of %s has %d changes", n, path, len(history))
+	}
+
+	current, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return Change{}, err
+	}
+
+	if string(current) != history[len(history)-1].After {
+		return Change{}, fmt.Errorf("%s was changed since its last recorded change. Restore one of its backups instead", path)
+	}
+
+	target := history[len(history)-n]
+	command := fmt.Sprintf("undo %d", n)
+
+	if target.Created {
+		err = removeFile(path, command)
+	} else {
+		err = replaceFile(path, []byte(target.Before), command)
+	}
+
+	return target, err
+}
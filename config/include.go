@@ -0,0 +1,277 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/mecha/tags/utils"
+)
+
+// Directives are the keys in config files that start with "$". They are not
+// tags or rule types, and are kept as is when config files are changed.
+const (
+	// IncludeKey is a list of other config files to merge into the file.
+	// Relative paths are relative to the including file, and may use globs.
+	IncludeKey = "$include"
+
+	// FragmentsKey is an object of named, reusable sets of rules.
+	FragmentsKey = "$fragments"
+
+	// UseKey is a list of the names of fragments whose rules a tag, or
+	// another fragment, should include.
+	UseKey = "$use"
+)
+
+type (
+	// fragments maps fragment names to their undecoded definitions.
+	fragments map[string]map[string]interface{}
+
+	// resolvedFile is a config file whose includes and fragments are
+	// resolved.
+	resolvedFile struct {
+		cfg Config
+
+		// origins records the file that defines each rule, which is an
+		// included file for the rules that come from it, and the file that
+		// defines the fragment for the rules that come from fragments.
+		origins Origins
+
+		frags fragments
+
+		// fragFiles maps fragment names to the files that define them.
+		fragFiles map[string]string
+	}
+)
+
+func isDirective(key string) bool {
+	return strings.HasPrefix(key, "$")
+}
+
+// resolveFile reads a config file, along with the files that it includes,
+// and resolves the fragments that its tags use. Rules in the file take
+// precedence over the rules in included files, and later includes take
+// precedence over earlier ones. The stack holds the files that are being
+// included, to detect cycles.
+func resolveFile(path string, stack []string) (*resolvedFile, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if contains(stack, abs) {
+		return nil, fmt.Errorf("include cycle: %s -> %s", strings.Join(stack, " -> "), abs)
+	}
+
+	stack = append(stack, abs)
+
+	wrap := func(err error) error {
+		return fmt.Errorf("%s: %s", abs, err)
+	}
+
+	doc, err := readDoc(abs)
+	if err != nil {
+		return nil, wrap(err)
+	}
+
+	result := &resolvedFile{
+		cfg:       make(Config),
+		origins:   make(Origins),
+		frags:     make(fragments),
+		fragFiles: make(map[string]string),
+	}
+
+	includes, err := includePaths(abs, doc[IncludeKey])
+	if err != nil {
+		return nil, wrap(err)
+	}
+
+	for _, include := range includes {
+		inc, err := resolveFile(include, stack)
+		if err != nil {
+			return nil, err
+		}
+
+		result.merge(inc.cfg, inc.origins)
+
+		for name, frag := range inc.frags {
+			result.frags[name] = frag
+			result.fragFiles[name] = inc.fragFiles[name]
+		}
+	}
+
+	if val, ok := doc[FragmentsKey]; ok {
+		dict, ok := val.(map[string]interface{})
+		if !ok {
+			return nil, wrap(fmt.Errorf("\"%s\" is not an object: %v", FragmentsKey, val))
+		}
+
+		for name, fragVal := range dict {
+			frag, ok := fragVal.(map[string]interface{})
+			if !ok {
+				return nil, wrap(fmt.Errorf("fragment \"%s\" is not an object: %v", name, fragVal))
+			}
+
+			result.frags[name] = frag
+			result.fragFiles[name] = path
+		}
+	}
+
+	for name, tagVal := range doc {
+		if isDirective(name) {
+			continue
+		}
+
+		tagDoc, ok := tagVal.(map[string]interface{})
+		if !ok {
+			return nil, wrap(fmt.Errorf("tag \"%s\" is not an object: %v", name, tagVal))
+		}
+
+		tagCfg, tagOrigins, err := result.resolveRules(tagDoc, path, nil)
+		if err != nil {
+			return nil, wrap(fmt.Errorf("tag \"%s\": %s", name, err))
+		}
+
+		result.merge(Config{name: tagCfg}, Origins{name: tagOrigins})
+	}
+
+	return result, nil
+}
+
+// merge merges tags into the file's config, along with the origins of their
+// rules.
+func (r *resolvedFile) merge(cfg Config, origins Origins) {
+	mergeConfig(r.cfg, cfg)
+
+	for name, tagOrigins := range origins {
+		if r.origins[name] == nil {
+			r.origins[name] = make(map[string]string)
+		}
+
+		for rType, origin := range tagOrigins {
+			r.origins[name][rType] = origin
+		}
+	}
+}
+
+// includePaths returns the paths of the files in an include directive,
+// relative to the including file.
+func includePaths(path string, val interface{}) ([]string, error) {
+	if val == nil {
+		return nil, nil
+	}
+
+	list, ok := val.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("\"%s\" is not a list: %v", IncludeKey, val)
+	}
+
+	result := make([]string, 0, len(list))
+
+	for _, item := range list {
+		include, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid value in \"%s\": %v", IncludeKey, item)
+		}
+
+		include, err := expander().Expand(include)
+		if err != nil {
+			return nil, err
+		}
+
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(path), include)
+		}
+
+		if !utils.HasGlobMeta(include) {
+			result = append(result, include)
+			continue
+		}
+
+		matches, err := filepath.Glob(include)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include pattern \"%s\": %s", include, err)
+		}
+
+		result = append(result, matches...)
+	}
+
+	return result, nil
+}
+
+// resolveRules returns the rules of a tag or fragment that is defined in a
+// file, including the rules of the fragments that it uses, along with the
+// files that define them. Its own rules take precedence over the fragments'
+// rules. The stack holds the fragments that are being resolved, to detect
+// cycles.
+func (r *resolvedFile) resolveRules(doc map[string]interface{}, path string, stack []string) (TagConfig, map[string]string, error) {
+	result := make(TagConfig)
+	origins := make(map[string]string)
+
+	uses := make([]string, 0)
+	if val, ok := doc[UseKey]; ok {
+		list, ok := val.([]interface{})
+		if !ok {
+			return nil, nil, fmt.Errorf("\"%s\" is not a list: %v", UseKey, val)
+		}
+
+		for _, item := range list {
+			name, ok := item.(string)
+			if !ok {
+				return nil, nil, fmt.Errorf("invalid value in \"%s\": %v", UseKey, item)
+			}
+
+			uses = append(uses, name)
+		}
+	}
+
+	for _, name := range uses {
+		if contains(stack, name) {
+			return nil, nil, fmt.Errorf("fragment cycle: %s -> %s", strings.Join(stack, " -> "), name)
+		}
+
+		frag, ok := r.frags[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("fragment \"%s\" not found", name)
+		}
+
+		fragCfg, fragOrigins, err := r.resolveRules(frag, r.fragFiles[name], append(stack, name))
+		if err != nil {
+			if len(stack) == 0 {
+				err = fmt.Errorf("fragment \"%s\": %s", name, err)
+			}
+			return nil, nil, err
+		}
+
+		for rType, ruleCfg := range fragCfg {
+			result[rType] = ruleCfg
+			origins[rType] = fragOrigins[rType]
+		}
+	}
+
+	own, err := tagConfigFromDoc(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for rType, ruleCfg := range own {
+		result[rType] = ruleCfg
+		origins[rType] = path
+	}
+
+	return result, origins, nil
+}
+
+// mergeConfig merges the tags of src into dst. Rules in src replace the rules
+// of the same type in the same tag in dst.
+func mergeConfig(dst Config, src Config) {
+	for name, tagCfg := range src {
+		if dst[name] == nil {
+			dst[name] = make(TagConfig)
+		}
+
+		for rType, ruleCfg := range tagCfg {
+			dst[name][rType] = ruleCfg
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/OpenPeeDeeP/xdg"
+	"github.com/mecha/tags/rules"
+	"github.com/mecha/tags/tags"
+)
+
+type (
+	// Origins records the file that defines each rule, by tag name and then
+	// by rule type. Rules from included files and fragments record the file
+	// that they are written in.
+	Origins map[string]map[string]string
+)
+
+const dropInDirName = "rules.d"
+
+// LayerPaths returns the config files that make up the layered config, from
+// the lowest to the highest precedence: the system-wide files in
+// $XDG_CONFIG_DIRS, followed by the user's file and then by the drop-in files
+// in the "rules.d" directories next to each file, in lexical order. Each file
+// may be in any of the supported formats.
+func LayerPaths() []string {
+	paths := make([]string, 0)
+
+	// The first system directory is the most important, so it comes last.
+	sysDirs := xdg.ConfigDirs()
+	for i := len(sysDirs) - 1; i >= 0; i-- {
+		dir := filepath.Join(sysDirs[i], "tags")
+		paths = append(paths, findConfigFile(filepath.Join(dir, "rules")))
+		paths = append(paths, dropIns(dir)...)
+	}
+
+	userPath := DefaultPath()
+	paths = append(paths, userPath)
+	paths = append(paths, dropIns(filepath.Dir(userPath))...)
+
+	return paths
+}
+
+// dropIns returns the config files in a directory's "rules.d" directory.
+func dropIns(dir string) []string {
+	matches := make([]string, 0)
+
+	for _, f := range formatExts {
+		found, err := filepath.Glob(filepath.Join(dir, dropInDirName, "*"+f.ext))
+		if err == nil {
+			matches = append(matches, found...)
+		}
+	}
+
+	sort.Strings(matches)
+
+	return matches
+}
+
+// ReadLayers reads and merges multiple config files, skipping files that do
+// not exist. Files later in the list take precedence: their rules replace
+// the rules of the same type in the same tag from earlier files.
+func ReadLayers(paths []string) (map[string]tags.Tag, Origins, error) {
+	result := make(map[string]tags.Tag)
+	origins := make(Origins)
+
+	for _, path := range paths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+
+		layer, layerOrigins, err := readWithOrigins(path)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for name, layerTag := range layer {
+			tag := result[name]
+
+			if origins[name] == nil {
+				origins[name] = make(map[string]string)
+			}
+
+			for _, rule := range layerTag.Rules {
+				tag.SetRule(rule)
+				rType := rules.GetType(rule)
+				origins[name][rType] = layerOrigins[name][rType]
+			}
+
+			result[name] = tag
+		}
+	}
+
+	return result, origins, nil
+}
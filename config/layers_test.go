@@ -0,0 +1,58 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadLayersOrigins(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"rules.json": `{
+  "$include": ["shared.json"],
+  "go": {"file_exists": {"files": ["go.mod"]}},
+  "react": {"$use": ["js"], "file_contains": {"search": {"package.json": "react"}}}
+}`,
+		"shared.json": `{
+  "$fragments": {"js": {"file_exists": {"files": ["package.json"]}}},
+  "go": {"executable": {"commands": ["go"]}},
+  "make": {"file_exists": {"files": ["Makefile"]}}
+}`,
+		"rules.d/local.json": `{
+  "make": {"file_exists": {"files": ["makefile"]}}
+}`,
+	}
+
+	for name, raw := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(raw), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	main := filepath.Join(dir, "rules.json")
+	shared := filepath.Join(dir, "shared.json")
+	local := filepath.Join(dir, "rules.d", "local.json")
+
+	_, origins, err := ReadLayers([]string{main, local})
+	if err != nil {
+		t.Fatalf("ReadLayers() error: %s", err)
+	}
+
+	want := Origins{
+		"go":    {"file_exists": main, "executable": shared},
+		"react": {"file_exists": shared, "file_contains": main},
+		"make":  {"file_exists": local},
+	}
+
+	if !reflect.DeepEqual(origins, want) {
+		t.Errorf("ReadLayers() origins = %v, want %v", origins, want)
+	}
+}
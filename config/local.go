@@ -0,0 +1,215 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mecha/tags/log"
+	"github.com/mecha/tags/rules"
+	"github.com/mecha/tags/tags"
+	"github.com/mecha/tags/utils"
+)
+
+type (
+	// LocalConfig is a project-local config file, which applies to the
+	// directory that contains it and all of its subdirectories.
+	LocalConfig struct {
+		// Tags that always apply.
+		Tags []string `json:"tags,omitempty"`
+		// Rules for additional tags, in the same format as the user config.
+		Rules Config `json:"rules,omitempty"`
+		// Tags from the user config that should not apply.
+		Suppress []string `json:"suppress,omitempty"`
+	}
+
+	// LocalFiles are the trusted local config files that apply to a
+	// directory, from the outermost to the innermost. They are read once, so
+	// that they can be applied to the directory and to each of its parents.
+	LocalFiles []localFile
+
+	localFile struct {
+		path  string
+		local *LocalConfig
+		rules map[string][]rules.Rule
+	}
+)
+
+const LocalFileName = ".tags.json"
+
+// FindLocal returns the paths of the local config files in a directory and
+// its parents, ordered from the outermost to the innermost.
+func FindLocal(dir string) ([]string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ancestors := utils.Ancestors(abs)
+	result := make([]string, 0)
+
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		path := filepath.Join(ancestors[i], LocalFileName)
+
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			result = append(result, path)
+		}
+	}
+
+	return result, nil
+}
+
+// ReadLocal reads a local config file, returning it along with its raw
+// contents for trust checks.
+func ReadLocal(path string) (*LocalConfig, []byte, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	local, err := parseLocal(path, raw)
+
+	return local, raw, err
+}
+
+func parseLocal(path string, raw []byte) (*LocalConfig, error) {
+	local := &LocalConfig{}
+	if err := json.Unmarshal(raw, local); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	return local, nil
+}
+
+// ApplyLocal merges the trusted local config files that apply to a directory
+// into the tags, and returns the tags that always apply. See ReadLocalFiles
+// and LocalFiles.Apply.
+func ApplyLocal(dir string, cfg map[string]tags.Tag, trust TrustDB) ([]string, error) {
+	files, err := ReadLocalFiles(dir, trust)
+	if err != nil {
+		return nil, err
+	}
+
+	return files.Apply(dir, cfg), nil
+}
+
+// ReadLocalFiles reads the trusted local config files that apply to a
+// directory. Untrusted files are skipped without being parsed, and files that
+// cannot be read or have invalid rules are skipped with an error, so that one
+// broken file does not affect the others.
+func ReadLocalFiles(dir string, trust TrustDB) (LocalFiles, error) {
+	paths, err := FindLocal(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(LocalFiles, 0, len(paths))
+
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			log.Error("Ignoring %s. %s\n", path, err)
+			continue
+		}
+
+		if !trust.IsTrusted(path, raw) {
+			log.Error("Ignoring untrusted %s. Run \"tags allow %s\" to trust it.\n", path, filepath.Dir(path))
+			continue
+		}
+
+		local, err := parseLocal(path, raw)
+		if err != nil {
+			log.Error("Ignoring %s\n", err)
+			continue
+		}
+
+		localTags, err := localRules(path, local)
+		if err != nil {
+			log.Error("Ignoring %s\n", err)
+			continue
+		}
+
+		result = append(result, localFile{path, local, localTags})
+	}
+
+	return result, nil
+}
+
+// Apply merges the files that apply to a directory into the tags, and returns
+// the tags that always apply. The directory is the one that the files were
+// read for, or one of its parents, to which only the files in it and in its
+// own parents apply. Inner files take precedence over outer ones.
+func (files LocalFiles) Apply(dir string, cfg map[string]tags.Tag) []string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+
+	always := make([]string, 0)
+
+	for _, file := range files {
+		if !utils.IsWithin(dir, filepath.Dir(file.path)) {
+			continue
+		}
+
+		log.Info("Applying %s\n", file.path)
+
+		for _, name := range file.local.Suppress {
+			delete(cfg, name)
+			always = removeString(always, name)
+		}
+
+		for name, ruleList := range file.rules {
+			// The rules are copied, so that the tags that the config shares
+			// with other configs are not changed.
+			tag := cfg[name]
+			tag.Rules = append([]rules.Rule(nil), tag.Rules...)
+
+			for _, rule := range ruleList {
+				tag.SetRule(rule)
+			}
+
+			cfg[name] = tag
+		}
+
+		for _, name := range file.local.Tags {
+			always = append(removeString(always, name), name)
+		}
+	}
+
+	return always
+}
+
+// localRules creates the rules of a local config file, so that a file is
+// either applied whole or not at all.
+func localRules(path string, local *LocalConfig) (map[string][]rules.Rule, error) {
+	expanded, err := expandConfig(path, local.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]rules.Rule, len(expanded))
+
+	for name, tagCfg := range expanded {
+		ruleList, err := ruleListFromConfig(tagCfg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: tag \"%s\": %s", path, name, err)
+		}
+
+		result[name] = ruleList
+	}
+
+	return result, nil
+}
+
+func removeString(list []string, value string) []string {
+	result := make([]string, 0, len(list))
+
+	for _, item := range list {
+		if item != value {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
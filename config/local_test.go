@@ -0,0 +1,80 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/mecha/tags/tags"
+)
+
+func TestLocalFilesApply(t *testing.T) {
+	dir := t.TempDir()
+	inner := filepath.Join(dir, "project", "pkg")
+
+	files := map[string]string{
+		"project/" + LocalFileName:     `{"tags": ["project"], "rules": {"go": {"file_exists": {"files": ["go.work"]}}}}`,
+		"project/pkg/" + LocalFileName: `{"tags": ["pkg"], "suppress": ["make"]}`,
+	}
+
+	trust := TrustDB{}
+
+	for name, raw := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(raw), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := trust.Allow(path, []byte(raw)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	local, err := ReadLocalFiles(inner, trust)
+	if err != nil {
+		t.Fatalf("ReadLocalFiles() error: %s", err)
+	}
+
+	tests := []struct {
+		name   string
+		dir    string
+		always []string
+		tags   []string
+		rules  int
+	}{
+		{"inner", inner, []string{"project", "pkg"}, []string{"go"}, 1},
+		{"parent", filepath.Dir(inner), []string{"project"}, []string{"go", "make"}, 1},
+		{"outside", dir, []string{}, []string{"make"}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := map[string]tags.Tag{"make": {}}
+			always := local.Apply(test.dir, cfg)
+
+			if !reflect.DeepEqual(always, test.always) {
+				t.Errorf("Apply() = %v, want %v", always, test.always)
+			}
+
+			names := make([]string, 0, len(cfg))
+			for name := range cfg {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			if !reflect.DeepEqual(names, test.tags) {
+				t.Errorf("tags = %v, want %v", names, test.tags)
+			}
+
+			if got := len(cfg["go"].Rules); got != test.rules {
+				t.Errorf("go rules = %d, want %d", got, test.rules)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !unix
+
+package config
+
+import (
+	"os"
+)
+
+// File locking is only supported on unix systems. Elsewhere, writes are still
+// atomic, but concurrent changes may overwrite each other.
+func lockFile(file *os.File) error {
+	return nil
+}
+
+func unlockFile(file *os.File) error {
+	return nil
+}
+
+func syncDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"os"
+)
+
+// VersionKey holds the version of a config file's format. Files without it
+// are at version 0, the format used before versions were introduced.
+const VersionKey = "$version"
+
+type (
+	// migration upgrades a config document by one version, in place.
+	migration struct {
+		description string
+		apply       func(doc map[string]interface{}) error
+	}
+)
+
+// migrations upgrade config documents from one version to the next: the
+// migration at index i upgrades documents from version i to i+1. To change
+// the format of a rule type's config, add a migration to the end of the list.
+var migrations = []migration{
+	{
+		description: "add the \"" + VersionKey + "\" field",
+		apply:       func(doc map[string]interface{}) error { return nil },
+	},
+}
+
+// LatestVersion returns the version of the current config file format.
+func LatestVersion() int {
+	return len(migrations)
+}
+
+// VersionOf returns the version of a config document.
+func VersionOf(doc map[string]interface{}) (int, error) {
+	val, ok := doc[VersionKey]
+	if !ok {
+		return 0, nil
+	}
+
+	num, ok := val.(float64)
+	if !ok || num < 0 || num != float64(int(num)) {
+		return 0, fmt.Errorf("\"%s\" is not a whole number: %v", VersionKey, val)
+	}
+
+	if int(num) > LatestVersion() {
+		return 0, fmt.Errorf("The config file is at version %d, but this version of tags only supports up to version %d", int(num), LatestVersion())
+	}
+
+	return int(num), nil
+}
+
+// migrate upgrades a config document to the latest version, in place, and
+// returns the version it was at.
+func migrate(doc map[string]interface{}) (int, error) {
+	version, err := VersionOf(doc)
+	if err != nil {
+		return 0, err
+	}
+
+	for v := version; v < LatestVersion(); v++ {
+		if err := migrations[v].apply(doc); err != nil {
+			return 0, fmt.Errorf("Could not migrate from version %d to %d (%s): %s", v, v+1, migrations[v].description, err)
+		}
+	}
+
+	doc[VersionKey] = float64(LatestVersion())
+
+	return version, nil
+}
+
+// Migrate upgrades a config file to the latest version. The original file is
+// kept as a backup, next to it, with its version in the name, such as
+// "rules.json.v0.bak". It returns the version that the file was at, and the
+// path of the backup, which is empty if the file was already up to date.
+func Migrate(path string) (int, string, error) {
+	unlock, err := Lock(path)
+	if err != nil {
+		return 0, "", err
+	}
+
+	defer unlock()
+
+	if _, err := os.Stat(path); err != nil {
+		return 0, "", err
+	}
+
+	f, err := readConfigFile(path)
+	if err != nil {
+		return 0, "", err
+	} else if f.from == LatestVersion() {
+		return f.from, "", nil
+	}
+
+	str, err := f.contents()
+	if err != nil {
+		return 0, "", err
+	}
+
+	backup := fmt.Sprintf("%s.v%d.bak", path, f.from)
+	if err := writeFile(backup, f.raw, 0644); err != nil {
+		return 0, "", err
+	}
+
+	return f.from, backup, replaceFile(path, str, "config migrate")
+}
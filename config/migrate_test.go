@@ -0,0 +1,128 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	react := map[string]interface{}{
+		"file_contains": map[string]interface{}{
+			"search": map[string]interface{}{"package.json": "react"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		doc     map[string]interface{}
+		want    map[string]interface{}
+		from    int
+		wantErr bool
+	}{
+		{
+			name: "version 0",
+			doc:  map[string]interface{}{"react": react},
+			want: map[string]interface{}{VersionKey: 1.0, "react": react},
+			from: 0,
+		},
+		{
+			name: "version 1",
+			doc:  map[string]interface{}{VersionKey: 1.0, "react": react},
+			want: map[string]interface{}{VersionKey: 1.0, "react": react},
+			from: 1,
+		},
+		{
+			name:    "newer version",
+			doc:     map[string]interface{}{VersionKey: 2.0},
+			wantErr: true,
+		},
+		{
+			name:    "invalid version",
+			doc:     map[string]interface{}{VersionKey: "1"},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// The document is copied, since migrations change it in place.
+			doc := toPlain(mergeValue(nil, test.doc)).(map[string]interface{})
+
+			from, err := migrate(doc)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("migrate() did not fail")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("migrate() error: %s", err)
+			}
+
+			if from != test.from {
+				t.Errorf("migrate() = %d, want %d", from, test.from)
+			}
+
+			if !reflect.DeepEqual(doc, test.want) {
+				t.Errorf("migrate() changed the document to %v, want %v", doc, test.want)
+			}
+		})
+	}
+}
+
+func TestMigrateFile(t *testing.T) {
+	before := `# Go projects
+go:
+  file_exists:
+    files: [go.mod]
+
+# React projects
+react:
+  file_contains:
+    search:
+      package.json: react
+`
+	after := "$version: 1\n\n" + before
+
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(path, []byte(before), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	from, backup, err := Migrate(path)
+	if err != nil {
+		t.Fatalf("Migrate() error: %s", err)
+	}
+
+	if from != 0 || backup != path+".v0.bak" {
+		t.Errorf("Migrate() = %d, %q, want 0, %q", from, backup, path+".v0.bak")
+	}
+
+	if got, _ := os.ReadFile(path); string(got) != after {
+		t.Errorf("Migrate() wrote:\n%s\nwant:\n%s", got, after)
+	}
+
+	if got, _ := os.ReadFile(backup); string(got) != before {
+		t.Errorf("Migrate() backed up:\n%s\nwant:\n%s", got, before)
+	}
+
+	// Writing a file without a version does not add one, since the file reads
+	// the same without it.
+	if err := os.WriteFile(path, []byte(before), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error: %s", err)
+	}
+
+	if err := Write(path, data, "test"); err != nil {
+		t.Fatalf("Write() error: %s", err)
+	}
+
+	if got, _ := os.ReadFile(path); string(got) != before {
+		t.Errorf("Write() wrote:\n%s\nwant:\n%s", got, before)
+	}
+}
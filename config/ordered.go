@@ -0,0 +1,440 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// orderedMap is an object in a config document that keeps the order of
+	// its keys, so that changed files can be written in their original order.
+	// Its values are *orderedMap, []interface{}, string, json.Number, bool or
+	// nil.
+	orderedMap struct {
+		keys   []string
+		values map[string]interface{}
+	}
+
+	// fileStyle is the formatting of a config file that is kept when the file
+	// is changed.
+	fileStyle struct {
+		indent  string
+		newline bool
+	}
+)
+
+var defaultStyle = fileStyle{indent: "  ", newline: true}
+
+func newOrderedMap() *orderedMap {
+	return &orderedMap{values: make(map[string]interface{})}
+}
+
+func (m *orderedMap) get(key string) interface{} {
+	return m.values[key]
+}
+
+// set sets the value of a key, keeping its position if it exists, or adding
+// it to the end.
+func (m *orderedMap) set(key string, val interface{}) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+
+	m.values[key] = val
+}
+
+func (m *orderedMap) delete(key string) {
+	if _, ok := m.values[key]; !ok {
+		return
+	}
+
+	delete(m.values, key)
+
+	for i, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// moveToFront moves a key to the start of the map.
+func (m *orderedMap) moveToFront(key string) {
+	val, ok := m.values[key]
+	if !ok {
+		return
+	}
+
+	m.delete(key)
+	m.keys = append([]string{key}, m.keys...)
+	m.values[key] = val
+}
+
+// rename changes the key of a value, keeping its position.
+func (m *orderedMap) rename(key string, newKey string) {
+	val, ok := m.values[key]
+	if !ok {
+		return
+	}
+
+	for i, k := range m.keys {
+		if k == key {
+			m.keys[i] = newKey
+			break
+		}
+	}
+
+	delete(m.values, key)
+	m.values[newKey] = val
+}
+
+// clone returns a copy of the map, which shares its values.
+func (m *orderedMap) clone() *orderedMap {
+	result := &orderedMap{keys: append([]string{}, m.keys...), values: make(map[string]interface{}, m.len())}
+	for key, val := range m.values {
+		result.values[key] = val
+	}
+
+	return result
+}
+
+func (m *orderedMap) len() int {
+	return len(m.keys)
+}
+
+// parseOrdered parses the contents of a config file, keeping the order of
+// keys, without migrating it.
+func parseOrdered(format Format, raw []byte) (*orderedMap, error) {
+	var (
+		doc interface{}
+		err error
+	)
+
+	switch format {
+	case JSON, JSONC:
+		doc, err = decodeOrderedJSON(raw)
+	case YAML:
+		doc, err = decodeOrderedYAML(raw)
+	case TOML:
+		doc, err = decodeOrderedTOML(raw)
+	default:
+		err = fmt.Errorf("Unknown config file format: %s", format)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if doc == nil {
+		return newOrderedMap(), nil
+	}
+
+	ordered, ok := doc.(*orderedMap)
+	if !ok {
+		return nil, fmt.Errorf("The config is not an object")
+	}
+
+	return ordered, nil
+}
+
+// migrateOrdered returns a document migrated to the latest version, and the
+// version that it was at. The document itself is not changed.
+func migrateOrdered(doc *orderedMap) (*orderedMap, int, error) {
+	// Migrations work on plain documents, so the migrated document is merged
+	// back into the ordered one to restore the order of the keys.
+	plain := toPlain(doc).(map[string]interface{})
+
+	from, err := migrate(plain)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	result := mergeValue(doc, plainValue(plain)).(*orderedMap)
+
+	if _, ok := doc.values[VersionKey]; !ok {
+		result.moveToFront(VersionKey)
+	}
+
+	return result, from, nil
+}
+
+func decodeOrderedJSON(raw []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(StripJSONComments(raw)))
+	dec.UseNumber()
+
+	var read func() (interface{}, error)
+
+	read = func() (interface{}, error) {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok {
+		case json.Delim('{'):
+			obj := newOrderedMap()
+
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+
+				val, err := read()
+				if err != nil {
+					return nil, err
+				}
+
+				obj.set(key.(string), val)
+			}
+
+			_, err = dec.Token()
+
+			return obj, err
+		case json.Delim('['):
+			list := make([]interface{}, 0)
+
+			for dec.More() {
+				val, err := read()
+				if err != nil {
+					return nil, err
+				}
+
+				list = append(list, val)
+			}
+
+			_, err = dec.Token()
+
+			return list, err
+		default:
+			return tok, nil
+		}
+	}
+
+	if len(bytes.TrimSpace(StripJSONComments(raw))) == 0 {
+		return nil, nil
+	}
+
+	return read()
+}
+
+func decodeOrderedYAML(raw []byte) (interface{}, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(raw, &root); err != nil {
+		return nil, err
+	}
+
+	var convert func(node *yaml.Node) (interface{}, error)
+
+	convert = func(node *yaml.Node) (interface{}, error) {
+		switch node.Kind {
+		case yaml.DocumentNode:
+			if len(node.Content) == 0 {
+				return nil, nil
+			}
+			return convert(node.Content[0])
+		case yaml.AliasNode:
+			return convert(node.Alias)
+		case yaml.MappingNode:
+			obj := newOrderedMap()
+
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				val, err := convert(node.Content[i+1])
+				if err != nil {
+					return nil, err
+				}
+
+				obj.set(node.Content[i].Value, val)
+			}
+
+			return obj, nil
+		case yaml.SequenceNode:
+			list := make([]interface{}, 0, len(node.Content))
+
+			for _, child := range node.Content {
+				val, err := convert(child)
+				if err != nil {
+					return nil, err
+				}
+
+				list = append(list, val)
+			}
+
+			return list, nil
+		default:
+			var val interface{}
+			if err := node.Decode(&val); err != nil {
+				return nil, err
+			}
+
+			return plainValue(val), nil
+		}
+	}
+
+	if root.Kind == 0 {
+		return nil, nil
+	}
+
+	return convert(&root)
+}
+
+// decodeOrderedTOML decodes a TOML document, and then orders its keys in the
+// order in which they appear in the file.
+func decodeOrderedTOML(raw []byte) (interface{}, error) {
+	doc := make(map[string]interface{})
+
+	meta, err := toml.Decode(string(raw), &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	skeleton := newOrderedMap()
+
+	for _, key := range meta.Keys() {
+		table := skeleton
+
+		for _, part := range key[:len(key)-1] {
+			next, ok := table.get(part).(*orderedMap)
+			if !ok {
+				next = newOrderedMap()
+				table.set(part, next)
+			}
+			table = next
+		}
+
+		if _, ok := table.values[key[len(key)-1]]; !ok {
+			table.set(key[len(key)-1], newOrderedMap())
+		}
+	}
+
+	return mergeValue(skeleton, plainValue(doc)), nil
+}
+
+// plainValue converts a value, such as a rule's config, to the plain types
+// produced by encoding/json, with json.Number for numbers.
+func plainValue(val interface{}) interface{} {
+	raw, err := json.Marshal(val)
+	if err != nil {
+		return val
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	var result interface{}
+	if err = dec.Decode(&result); err != nil {
+		return val
+	}
+
+	return result
+}
+
+// toPlain converts an ordered value to the types produced by decode.
+func toPlain(val interface{}) interface{} {
+	switch val := val.(type) {
+	case *orderedMap:
+		result := make(map[string]interface{}, val.len())
+		for _, key := range val.keys {
+			result[key] = toPlain(val.values[key])
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(val))
+		for i, item := range val {
+			result[i] = toPlain(item)
+		}
+		return result
+	case json.Number:
+		num, _ := val.Float64()
+		return num
+	default:
+		return val
+	}
+}
+
+// mergeValue returns a plain value as an ordered value, keeping the order of
+// the keys that are also in the old ordered value. New keys are added at the
+// end, in lexical order.
+func mergeValue(old interface{}, val interface{}) interface{} {
+	switch val := val.(type) {
+	case map[string]interface{}:
+		result := newOrderedMap()
+		oldMap, _ := old.(*orderedMap)
+
+		if oldMap != nil {
+			for _, key := range oldMap.keys {
+				if newVal, ok := val[key]; ok {
+					result.set(key, mergeValue(oldMap.values[key], newVal))
+				}
+			}
+		}
+
+		for _, key := range sortedKeys(val) {
+			if _, ok := result.values[key]; !ok {
+				result.set(key, mergeValue(nil, val[key]))
+			}
+		}
+
+		return result
+	case []interface{}:
+		oldList, _ := old.([]interface{})
+		result := make([]interface{}, len(val))
+
+		for i, item := range val {
+			var oldItem interface{}
+			if i < len(oldList) {
+				oldItem = oldList[i]
+			}
+			result[i] = mergeValue(oldItem, item)
+		}
+
+		return result
+	case float64:
+		return json.Number(strconv.FormatFloat(val, 'f', -1, 64))
+	default:
+		return val
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// detectStyle finds the indentation of a config file, from its first
+// indented line, and whether it ends with a newline.
+func detectStyle(raw []byte) fileStyle {
+	style := defaultStyle
+
+	if len(raw) == 0 {
+		return style
+	}
+
+	style.newline = bytes.HasSuffix(raw, []byte("\n"))
+
+	for _, line := range strings.Split(string(raw), "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+
+		if trimmed == "" || trimmed == line || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "//") {
+			continue
+		}
+
+		style.indent = line[:len(line)-len(trimmed)]
+		break
+	}
+
+	return style
+}
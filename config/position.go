@@ -0,0 +1,255 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// Position is a line and column in a config file, both starting at 1.
+	Position struct {
+		Line int
+		Col  int
+	}
+
+	// positions maps the key paths in a config document to the positions of
+	// the keys in the file.
+	positions map[string]Position
+)
+
+// IsValid reports whether the position is known.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func keyPath(keys ...string) string {
+	return strings.Join(keys, "\x00")
+}
+
+// lookup returns the position of the key at the given path, or of its
+// closest parent if the key's position is not known.
+func (p positions) lookup(keys ...string) Position {
+	for n := len(keys); n > 0; n-- {
+		if pos, ok := p[keyPath(keys[:n]...)]; ok {
+			return pos
+		}
+	}
+
+	return Position{}
+}
+
+// indexPositions finds the positions of the keys in a config file. It is
+// best-effort: keys whose positions cannot be found are left out.
+func indexPositions(format Format, raw []byte) positions {
+	result := make(positions)
+
+	switch format {
+	case JSON, JSONC:
+		indexJSON(raw, result)
+	case YAML:
+		indexYAML(raw, result)
+	case TOML:
+		indexTOML(raw, result)
+	}
+
+	return result
+}
+
+func indexJSON(raw []byte, result positions) {
+	stripped := StripJSONComments(raw)
+	dec := json.NewDecoder(bytes.NewReader(stripped))
+
+	var walk func(path []string) error
+
+	walk = func(path []string) error {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		delim, ok := tok.(json.Delim)
+		if !ok {
+			return nil
+		}
+
+		for i := 0; dec.More(); i++ {
+			keys := append(path[:len(path):len(path)], strconv.Itoa(i))
+
+			if delim == '{' {
+				start := skipJSONSeparators(stripped, int(dec.InputOffset()))
+
+				tok, err := dec.Token()
+				if err != nil {
+					return err
+				}
+
+				key, _ := tok.(string)
+				keys[len(keys)-1] = key
+				result[keyPath(keys...)] = offsetPosition(raw, start)
+			}
+
+			if err := walk(keys); err != nil {
+				return err
+			}
+		}
+
+		_, err = dec.Token()
+
+		return err
+	}
+
+	walk(nil)
+}
+
+// skipJSONSeparators returns the offset of the next token after offset.
+func skipJSONSeparators(raw []byte, offset int) int {
+	for offset < len(raw) && strings.IndexByte(" \t\r\n,:", raw[offset]) >= 0 {
+		offset++
+	}
+
+	return offset
+}
+
+func indexYAML(raw []byte, result positions) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(raw, &root); err != nil {
+		return
+	}
+
+	var walk func(node *yaml.Node, path []string)
+
+	walk = func(node *yaml.Node, path []string) {
+		switch node.Kind {
+		case yaml.DocumentNode:
+			for _, child := range node.Content {
+				walk(child, path)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key, val := node.Content[i], node.Content[i+1]
+				keys := append(path[:len(path):len(path)], key.Value)
+
+				result[keyPath(keys...)] = Position{key.Line, key.Column}
+				walk(val, keys)
+			}
+		case yaml.SequenceNode:
+			for i, child := range node.Content {
+				walk(child, append(path[:len(path):len(path)], strconv.Itoa(i)))
+			}
+		}
+	}
+
+	walk(&root, nil)
+}
+
+// indexTOML finds the positions of table headers and "key = value" lines. It
+// does not look inside inline tables and multi-line values.
+func indexTOML(raw []byte, result positions) {
+	var table []string
+
+	for i, line := range strings.Split(string(raw), "\n") {
+		trimmed := strings.TrimSpace(line)
+		pos := Position{i + 1, len(line) - len(strings.TrimLeft(line, " \t")) + 1}
+
+		switch {
+		case trimmed == "" || strings.HasPrefix(trimmed, "#"):
+			continue
+		case strings.HasPrefix(trimmed, "[") && !strings.HasPrefix(trimmed, "[["):
+			end := strings.LastIndex(trimmed, "]")
+			if end < 0 {
+				continue
+			}
+
+			table = splitTOMLKey(trimmed[1:end])
+
+			for n := 1; n <= len(table); n++ {
+				if _, ok := result[keyPath(table[:n]...)]; !ok {
+					result[keyPath(table[:n]...)] = pos
+				}
+			}
+		default:
+			eq := strings.Index(trimmed, "=")
+			if eq < 0 {
+				continue
+			}
+
+			keys := append(table[:len(table):len(table)], splitTOMLKey(trimmed[:eq])...)
+			result[keyPath(keys...)] = pos
+		}
+	}
+}
+
+// splitTOMLKey splits a dotted TOML key into its parts, removing quotes.
+func splitTOMLKey(key string) []string {
+	result := make([]string, 0)
+	part := strings.Builder{}
+	var quote rune
+
+	for _, c := range key {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			part.WriteRune(c)
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '.':
+			result = append(result, strings.TrimSpace(part.String()))
+			part.Reset()
+		default:
+			part.WriteRune(c)
+		}
+	}
+
+	return append(result, strings.TrimSpace(part.String()))
+}
+
+// offsetPosition converts a byte offset in a file to a position.
+func offsetPosition(raw []byte, offset int) Position {
+	if offset > len(raw) {
+		offset = len(raw)
+	} else if offset < 0 {
+		offset = 0
+	}
+
+	before := raw[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := offset - bytes.LastIndexByte(before, '\n')
+
+	return Position{line, col}
+}
+
+var yamlErrorLine = regexp.MustCompile(`line (\d+)`)
+
+// errorPosition returns the position of an error returned by decode.
+func errorPosition(raw []byte, err error) Position {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		tomlErr   toml.ParseError
+	)
+
+	switch {
+	case errors.As(err, &syntaxErr):
+		// The offset is after the invalid character.
+		return offsetPosition(raw, int(syntaxErr.Offset)-1)
+	case errors.As(err, &typeErr):
+		return offsetPosition(raw, int(typeErr.Offset))
+	case errors.As(err, &tomlErr):
+		return offsetPosition(raw, tomlErr.Position.Start)
+	}
+
+	if match := yamlErrorLine.FindStringSubmatch(err.Error()); match != nil {
+		line, _ := strconv.Atoi(match[1])
+		return Position{line, 1}
+	}
+
+	return Position{}
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"encoding/json"
+
+	"github.com/mecha/tags/rules"
+)
+
+// SchemaID is the URI of the JSON Schema draft that Schema generates.
+const SchemaID = "http://json-schema.org/draft-07/schema#"
+
+// Schema generates a JSON Schema for config files from the registered rule
+// types and their config keys.
+func Schema() map[string]interface{} {
+	stringList := map[string]interface{}{
+		"type":  "array",
+		"items": map[string]interface{}{"type": "string"},
+	}
+
+	definitions := make(map[string]interface{})
+	ruleProps := map[string]interface{}{
+		UseKey: withDescription(stringList, "The names of the fragments whose rules to use."),
+	}
+
+	for _, rType := range rules.Types() {
+		def := fieldsSchema(rules.Fields(rType))
+		def["description"] = rules.Describe(rType)
+
+		// Unknown keys in rules are reported as warnings and kept, so they
+		// are allowed.
+		delete(def, "additionalProperties")
+
+		definitions[rType] = def
+		ruleProps[rType] = map[string]interface{}{"$ref": "#/definitions/" + rType}
+	}
+
+	definitions["rules"] = map[string]interface{}{
+		"type":                 "object",
+		"description":          "The rules of a tag. The tag applies if any of its rules match.",
+		"properties":           ruleProps,
+		"additionalProperties": false,
+	}
+
+	rulesRef := map[string]interface{}{"$ref": "#/definitions/rules"}
+
+	return map[string]interface{}{
+		"$schema": SchemaID,
+		"title":   "tags config",
+		"type":    "object",
+		"properties": map[string]interface{}{
+			VersionKey: map[string]interface{}{
+				"type":        "integer",
+				"description": "The version of the config file's format.",
+				"minimum":     0,
+				"maximum":     LatestVersion(),
+			},
+			IncludeKey: withDescription(stringList, "Other config files to include, relative to this file."),
+			FragmentsKey: map[string]interface{}{
+				"type":                 "object",
+				"description":          "Named sets of rules that tags can use with \"" + UseKey + "\".",
+				"additionalProperties": rulesRef,
+			},
+		},
+		"additionalProperties": rulesRef,
+		"definitions":          definitions,
+	}
+}
+
+// SchemaJSON returns the JSON Schema for config files, indented.
+func SchemaJSON() ([]byte, error) {
+	return json.MarshalIndent(Schema(), "", "  ")
+}
+
+// fieldsSchema returns the schema of an object with the given fields.
+func fieldsSchema(fields []rules.Field) map[string]interface{} {
+	props := make(map[string]interface{}, len(fields))
+	required := make([]string, 0)
+
+	for _, field := range fields {
+		props[field.Name] = fieldSchema(field)
+
+		if field.Required {
+			required = append(required, field.Name)
+		}
+	}
+
+	result := map[string]interface{}{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+
+	if len(required) > 0 {
+		result["required"] = required
+	}
+
+	return result
+}
+
+func fieldSchema(field rules.Field) map[string]interface{} {
+	var result map[string]interface{}
+
+	switch field.Type {
+	case rules.ListField:
+		result = map[string]interface{}{
+			"type":  "array",
+			"items": map[string]interface{}{"type": "string"},
+		}
+	case rules.MapField:
+		var values interface{} = map[string]interface{}{"type": "string"}
+		if field.Fields != nil {
+			values = fieldsSchema(field.Fields)
+		}
+
+		result = map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": values,
+		}
+	case rules.SizeField:
+		result = map[string]interface{}{"type": []string{"integer", "string"}}
+	default:
+		result = map[string]interface{}{"type": string(field.Type)}
+	}
+
+	return withDescription(result, field.Description)
+}
+
+// withDescription returns a copy of a schema with a description.
+func withDescription(schema map[string]interface{}, description string) map[string]interface{} {
+	result := make(map[string]interface{}, len(schema)+1)
+	for k, v := range schema {
+		result[k] = v
+	}
+
+	if description != "" {
+		result["description"] = description
+	}
+
+	return result
+}
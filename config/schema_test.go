@@ -0,0 +1,29 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestSchemaRules(t *testing.T) {
+	definitions := Schema()["definitions"].(map[string]interface{})
+
+	for name, def := range definitions {
+		if name == "rules" {
+			continue
+		}
+
+		if _, ok := def.(map[string]interface{})["additionalProperties"]; ok {
+			t.Errorf("rule type %s does not allow unknown keys", name)
+		}
+	}
+
+	// Tags may only have known rule types.
+	if got := definitions["rules"].(map[string]interface{})["additionalProperties"]; got != false {
+		t.Errorf("rules allow unknown rule types: %v", got)
+	}
+
+	search := definitions["file_contains"].(map[string]interface{})["properties"].(map[string]interface{})["search"].(map[string]interface{})
+	if search["type"] != "object" {
+		t.Errorf("file_contains \"search\" has type %v, want object", search["type"])
+	}
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/OpenPeeDeeP/xdg"
+)
+
+type (
+	// TrustDB maps the absolute paths of local config files to the SHA-256
+	// hash of the contents that were allowed. Files are only trusted while
+	// their contents are unchanged.
+	TrustDB map[string]string
+)
+
+func TrustPath() string {
+	return xdg.DataHome() + "/tags/trusted.json"
+}
+
+// ReadTrust reads the trust database. A missing file is treated as empty.
+func ReadTrust(path string) (TrustDB, error) {
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return TrustDB{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	trust := TrustDB{}
+	if err = json.Unmarshal(raw, &trust); err != nil {
+		return nil, err
+	}
+
+	return trust, nil
+}
+
+func WriteTrust(path string, trust TrustDB) error {
+	str, err := json.MarshalIndent(trust, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFile(path, str, 0600)
+}
+
+func hashContents(contents []byte) string {
+	sum := sha256.Sum256(contents)
+	return hex.EncodeToString(sum[:])
+}
+
+func (trust TrustDB) IsTrusted(path string, contents []byte) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	return trust[abs] == hashContents(contents)
+}
+
+// Allow trusts the current contents of a file.
+func (trust TrustDB) Allow(path string, contents []byte) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	trust[abs] = hashContents(contents)
+
+	return nil
+}
+
+// Deny revokes the trust of a file, if it was trusted.
+func (trust TrustDB) Deny(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	delete(trust, abs)
+
+	return nil
+}
@@ -0,0 +1,223 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// MaxBackups is the number of previous versions of a config file that are
+// kept when it is changed, as "rules.json.1.bak" (the newest) to
+// "rules.json.3.bak" (the oldest).
+const MaxBackups = 3
+
+type (
+	heldLock struct {
+		file  *os.File
+		count int
+	}
+)
+
+var (
+	locksMu sync.Mutex
+	locks   = make(map[string]*heldLock)
+)
+
+// Lock takes an advisory lock on a config file, waiting until other processes
+// release it. The lock is held on a separate "<path>.lock" file, since config
+// files are replaced rather than changed in place. Locks are re-entrant
+// within a process. The returned function releases the lock.
+func Lock(path string) (func(), error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	locksMu.Lock()
+	defer locksMu.Unlock()
+
+	if held, ok := locks[abs]; ok {
+		held.count++
+		return func() { unlock(abs) }, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(abs), 0755); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(abs+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("Could not lock %s: %s", path, err)
+	}
+
+	locks[abs] = &heldLock{file, 1}
+
+	return func() { unlock(abs) }, nil
+}
+
+func unlock(abs string) {
+	locksMu.Lock()
+	defer locksMu.Unlock()
+
+	held, ok := locks[abs]
+	if !ok {
+		return
+	}
+
+	if held.count--; held.count == 0 {
+		unlockFile(held.file)
+		held.file.Close()
+		delete(locks, abs)
+	}
+}
+
+// writeFile replaces a file atomically, by writing to a temporary file in the
+// same directory, syncing it to disk and renaming it over the file. Missing
+// directories are created, and the mode of an existing file is kept.
+func writeFile(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err = os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	return syncDir(dir)
+}
+
+// WriteFile locks a file and replaces it atomically, like the config files
+// but without backups or history. It is used for the other files that are
+// changed by commands, such as the manual tag database.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	unlock, err := Lock(path)
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	return writeFile(path, data, perm)
+}
+
+// backupPath returns the path of the nth backup of a file.
+func backupPath(path string, n int) string {
+	return fmt.Sprintf("%s.%d.bak", path, n)
+}
+
+// rotateBackups copies a file to its first backup, shifting the previous
+// backups and removing the oldest. Nothing is done if the file does not
+// exist.
+func rotateBackups(path string) error {
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if err = os.Remove(backupPath(path, MaxBackups)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for n := MaxBackups - 1; n >= 1; n-- {
+		if err = os.Rename(backupPath(path, n), backupPath(path, n+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return writeFile(backupPath(path, 1), raw, 0644)
+}
+
+// replaceFile locks a config file, backs it up and replaces its contents. The
+// change is recorded in the file's history, with the command that made it.
+func replaceFile(path string, data []byte, command string) error {
+	return changeFile(path, data, false, command)
+}
+
+// removeFile locks a config file, backs it up and removes it. The change is
+// recorded in the file's history, with the command that made it.
+func removeFile(path string, command string) error {
+	return changeFile(path, nil, true, command)
+}
+
+// changeFile replaces a config file's contents, or removes the file if remove
+// is set. Empty contents are written as an empty file.
+func changeFile(path string, data []byte, remove bool, command string) error {
+	unlock, err := Lock(path)
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	before, err := os.ReadFile(path)
+	created := os.IsNotExist(err)
+	if err != nil && !created {
+		return err
+	}
+
+	if err = rotateBackups(path); err != nil {
+		return fmt.Errorf("Could not back up %s: %s", path, err)
+	}
+
+	if remove {
+		err = os.Remove(path)
+	} else {
+		err = writeFile(path, data, 0644)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = recordChange(path, Change{
+		Time:    time.Now(),
+		Command: command,
+		Before:  string(before),
+		After:   string(data),
+		Created: created,
+		Removed: remove,
+	})
+	if err != nil {
+		return fmt.Errorf("Could not record the change in the history of %s: %s", path, err)
+	}
+
+	return nil
+}
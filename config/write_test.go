@@ -0,0 +1,551 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// editTags reads the tags in a config file, changes them the way "tags add"
+// and "tags rm" do, and writes them back.
+func editTags(t *testing.T, path string) {
+	t.Helper()
+
+	data, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error: %s", err)
+	}
+
+	golang := data["go"]
+	if err := golang.AddRule("file_exists", []string{"go.work"}); err != nil {
+		t.Fatalf("AddRule() error: %s", err)
+	}
+	data["go"] = golang
+
+	node := data["node"]
+	if err := node.AddRule("executable", []string{"docker"}); err != nil {
+		t.Fatalf("AddRule() error: %s", err)
+	}
+	data["node"] = node
+
+	rust := data["rust"]
+	if err := rust.AddRule("file_exists", []string{"Cargo.toml"}); err != nil {
+		t.Fatalf("AddRule() error: %s", err)
+	}
+	data["rust"] = rust
+
+	delete(data, "old")
+
+	if err := Write(path, data, "test"); err != nil {
+		t.Fatalf("Write() error: %s", err)
+	}
+}
+
+func TestWriteKeepsUntouchedEntries(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+	}{
+		{
+			name: "rules.json",
+			before: `{
+  "go": {"file_exists": {"files": ["go.mod"]}},
+  "node": {
+    "file_exists": {
+      "files": ["package.json"]
+    }
+  },
+  "old": {
+    "file_exists": {"files": ["old.txt"]}
+  }
+}
+`,
+			after: `{
+  "go": {"file_exists": {"files": ["go.mod", "go.work"]}},
+  "node": {
+    "file_exists": {
+      "files": ["package.json"]
+    },
+    "executable": {
+      "commands": [
+        "docker"
+      ]
+    }
+  },
+  "rust": {
+    "file_exists": {
+      "files": [
+        "Cargo.toml"
+      ]
+    }
+  }
+}
+`,
+		},
+		{
+			name: "rules.jsonc",
+			before: `{
+  // Go projects
+  "go": {"file_exists": {"files": ["go.mod"]}},
+
+  /* Node projects */
+  "node": {
+    "file_exists": {
+      "files": ["package.json"], // the manifest
+    },
+  },
+  // Soon to be removed
+  "old": {
+    "file_exists": {"files": ["old.txt"]},
+  },
+}
+`,
+			after: `{
+  // Go projects
+  "go": {"file_exists": {"files": ["go.mod", "go.work"]}},
+
+  /* Node projects */
+  "node": {
+    "file_exists": {
+      "files": ["package.json"], // the manifest
+    },
+    "executable": {
+      "commands": [
+        "docker"
+      ]
+    },
+  },
+  "rust": {
+    "file_exists": {
+      "files": [
+        "Cargo.toml"
+      ]
+    }
+  },
+}
+`,
+		},
+		{
+			name: "rules.yaml",
+			before: `# Go projects
+go:
+  file_exists: {files: [go.mod]}
+
+# Node projects
+node:
+  file_exists:
+    # the manifest
+    files:
+    - package.json
+
+# Soon to be removed
+old:
+  file_exists:
+    files: [old.txt]
+`,
+			after: `# Go projects
+go:
+  file_exists: {files: [go.mod, go.work]}
+
+# Node projects
+node:
+  file_exists:
+    # the manifest
+    files:
+    - package.json
+  executable:
+    commands:
+      - docker
+
+rust:
+  file_exists:
+    files:
+      - Cargo.toml
+`,
+		},
+		{
+			name: "rules.toml",
+			before: `# Go projects
+[go.file_exists]
+files = ["go.mod"]
+
+# Node projects
+[node.file_exists]
+files = [
+  "package.json", # the manifest
+]
+
+# Soon to be removed
+[old.file_exists]
+files = ["old.txt"]
+`,
+			after: `# Go projects
+[go.file_exists]
+files = ["go.mod", "go.work"]
+
+# Node projects
+[node.file_exists]
+files = [
+  "package.json", # the manifest
+]
+
+[node.executable]
+commands = ["docker"]
+
+[rust.file_exists]
+files = ["Cargo.toml"]
+`,
+		},
+		{
+			name: "dotted keys/rules.toml",
+			before: `# Go projects
+go.file_exists.files = ["go.mod"]
+node.file_exists.files = ["package.json"] # the manifest
+old.file_exists.files = ["old.txt"]
+`,
+			after: `# Go projects
+go.file_exists.files = ["go.mod", "go.work"]
+node.file_exists.files = ["package.json"] # the manifest
+node.executable.commands = ["docker"]
+rust.file_exists.files = ["Cargo.toml"]
+`,
+		},
+		{
+			name: "tag tables/rules.toml",
+			before: `[go]
+file_exists.files = ["go.mod"]
+
+# Node projects
+[node]
+file_exists = { files = ["package.json"] } # the manifest
+
+[old]
+file_exists.files = ["old.txt"]
+`,
+			after: `[go]
+file_exists.files = ["go.mod", "go.work"]
+
+# Node projects
+[node]
+file_exists = { files = ["package.json"] } # the manifest
+executable = { commands = ["docker"] }
+
+[rust]
+file_exists.files = ["Cargo.toml"]
+`,
+		},
+		{
+			name: "mixed/rules.toml",
+			before: `go.file_exists.files = ["go.mod"]
+
+[node]
+file_exists.files = ["package.json"]
+
+[node.executable]
+commands = ["node"]
+
+[old]
+file_exists = { files = ["old.txt"] }
+`,
+			after: `go.file_exists.files = ["go.mod", "go.work"]
+
+[node]
+file_exists.files = ["package.json"]
+
+[node.executable]
+commands = ["node", "docker"]
+
+[rust]
+file_exists = { files = ["Cargo.toml"] }
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), filepath.Base(test.name))
+			if err := os.WriteFile(path, []byte(test.before), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			editTags(t, path)
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got) != test.after {
+				t.Errorf("Write() wrote:\n%s\nwant:\n%s", got, test.after)
+			}
+		})
+	}
+}
+
+func TestWriteUnchanged(t *testing.T) {
+	files := map[string]string{
+		"rules.json": `{
+  "$version": 1,
+  "$fragments": {
+    "js": {"file_exists": {"files": ["package.json"]}}
+  },
+  "go": {"file_exists": {"files": ["go.mod"]}},
+  "vue": {"$use": ["js"]},
+  "node": {
+    "$use": ["js"],
+    "file_exists": {
+      "files": ["package.json"],
+      "note": "an unknown key"
+    }
+  }
+}
+`,
+		"rules.jsonc": `// Tags
+{"go": {"file_exists": {"files": ["go.mod"],},}, /* node */ "node": {"executable": {"commands": ["node"]}}}`,
+		"rules.yaml": `go:
+    file_exists:
+        files: [go.mod] # the module
+node: {executable: {commands: [node]}}
+`,
+		"rules.toml": `go.file_exists.files = ["go.mod"]
+
+[node]
+executable = { commands = ['node'] } # inline
+`,
+	}
+
+	for name, raw := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			if err := os.WriteFile(path, []byte(raw), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := ReadFile(path)
+			if err != nil {
+				t.Fatalf("ReadFile() error: %s", err)
+			}
+
+			if err := Write(path, data, "test"); err != nil {
+				t.Fatalf("Write() error: %s", err)
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got) != raw {
+				t.Errorf("Write() wrote:\n%s\nwant:\n%s", got, raw)
+			}
+		})
+	}
+}
+
+func TestLostComments(t *testing.T) {
+	files := map[string]string{
+		"rules.jsonc": `{
+  "go": {
+    "file_exists": {
+      "files": ["go.mod"] // the module
+    },
+    "executable": {"commands": ["go"]} // the toolchain
+  },
+  // Node projects
+  "node": {"file_exists": {"files": ["package.json"]}}
+}
+`,
+		"rules.yaml": `go:
+  file_exists:
+    files: [go.mod] # the module
+  executable: {commands: [go]} # the toolchain
+# Node projects
+node: {file_exists: {files: [package.json]}}
+`,
+		"rules.toml": `[go.file_exists]
+files = ["go.mod"] # the module
+
+[go.executable]
+commands = ["go"] # the toolchain
+
+# Node projects
+[node.file_exists]
+files = ["package.json"]
+`,
+	}
+
+	for name, raw := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			if err := os.WriteFile(path, []byte(raw), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := readConfigFile(path)
+			if err != nil {
+				t.Fatalf("readConfigFile() error: %s", err)
+			}
+
+			// Only the file_exists rules are changed, so the comments of the
+			// executable rule and above the node tag are kept.
+			for _, tag := range []string{"go", "node"} {
+				rule := f.doc.get(tag).(*orderedMap).get("file_exists").(*orderedMap)
+				rule.set("files", []interface{}{"other"})
+			}
+
+			ed, err := newEditor(f.format, f.raw, f.style)
+			if err != nil {
+				t.Fatalf("newEditor() error: %s", err)
+			}
+
+			if err := diffEntries(ed, f.orig, f.doc); err != nil {
+				t.Fatalf("diffEntries() error: %s", err)
+			}
+
+			if got := ed.lostComments(); !reflect.DeepEqual(got, []string{"go.file_exists"}) {
+				t.Errorf("lostComments() = %q, want %q", got, []string{"go.file_exists"})
+			}
+		})
+	}
+}
+
+func TestRename(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+	}{
+		{
+			name: "rules.jsonc",
+			before: `{
+  "$fragments": {
+    "js": {"file_exists": {"files": ["package.json"]}}
+  },
+  // Vue projects
+  "vue": {"$use": ["js"]},
+  "go": {"file_exists": {"files": ["go.mod"]}}
+}
+`,
+			after: `{
+  "$fragments": {
+    "js": {"file_exists": {"files": ["package.json"]}}
+  },
+  // Vue projects
+  "vuejs": {"$use": ["js"]},
+  "go": {"file_exists": {"files": ["go.mod"]}}
+}
+`,
+		},
+		{
+			name: "rules.yaml",
+			before: `$fragments:
+  js: {file_exists: {files: [package.json]}}
+# Vue projects
+vue:
+  $use: [js]
+go: {file_exists: {files: [go.mod]}}
+`,
+			after: `$fragments:
+  js: {file_exists: {files: [package.json]}}
+# Vue projects
+vuejs:
+  $use: [js]
+go: {file_exists: {files: [go.mod]}}
+`,
+		},
+		{
+			name: "rules.toml",
+			before: `["$fragments".js.file_exists]
+files = ["package.json"]
+
+# Vue projects
+[vue]
+"$use" = ["js"]
+
+[go.file_exists]
+files = ["go.mod"]
+`,
+			after: `["$fragments".js.file_exists]
+files = ["package.json"]
+
+# Vue projects
+[vuejs]
+"$use" = ["js"]
+
+[go.file_exists]
+files = ["go.mod"]
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), test.name)
+			if err := os.WriteFile(path, []byte(test.before), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := Rename(path, "vue", "vuejs", "test"); err != nil {
+				t.Fatalf("Rename() error: %s", err)
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got) != test.after {
+				t.Errorf("Rename() wrote:\n%s\nwant:\n%s", got, test.after)
+			}
+
+			if err := Rename(path, "vuejs", "go", "test"); err == nil {
+				t.Errorf("Rename() to an existing tag did not fail")
+			}
+		})
+	}
+}
+
+func TestWriteEmptyDocument(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.toml")
+	raw := "[go.file_exists]\nfiles = [\"go.mod\"]\n"
+
+	if err := replaceFile(path, []byte(raw), "test"); err != nil {
+		t.Fatalf("replaceFile() error: %s", err)
+	}
+
+	data, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error: %s", err)
+	}
+
+	// Removing the last tag leaves an empty file, rather than removing it.
+	delete(data, "go")
+	if err := Write(path, data, "test"); err != nil {
+		t.Fatalf("Write() error: %s", err)
+	}
+
+	if got, err := os.ReadFile(path); err != nil {
+		t.Fatalf("ReadFile() error: %s", err)
+	} else if len(got) != 0 {
+		t.Errorf("Write() wrote %q, want an empty file", got)
+	}
+
+	if _, err := Undo(path, 1); err != nil {
+		t.Fatalf("Undo() error: %s", err)
+	}
+
+	if got, err := os.ReadFile(path); err != nil || string(got) != raw {
+		t.Errorf("Undo() restored %q (%v), want %q", got, err, raw)
+	}
+
+	// Undoing the change that created the file removes it.
+	if _, err := Undo(path, 3); err != nil {
+		t.Fatalf("Undo() error: %s", err)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Undo() of the file's creation kept the file: %v", err)
+	}
+}
@@ -2,6 +2,10 @@ module github.com/mecha/tags
 
 go 1.21.4
 
-require github.com/OpenPeeDeeP/xdg v1.0.0
+require (
+	github.com/BurntSushi/toml v1.3.2
+	github.com/OpenPeeDeeP/xdg v1.0.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require github.com/stretchr/testify v1.7.0 // indirect
@@ -4,8 +4,11 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/mecha/tags/config"
+	"github.com/mecha/tags/manual"
+	"github.com/mecha/tags/rules"
 )
 
 func helpCommand(args []string) {
@@ -23,6 +26,18 @@ func helpCommand(args []string) {
 		printAddHelp()
 	case "rm":
 		printRmHelp()
+	case "rename":
+		printRenameHelp()
+	case "history", "undo":
+		printHistoryHelp()
+	case "root":
+		printRootHelp()
+	case "mark", "unmark":
+		printMarkHelp()
+	case "tag", "untag":
+		printTagHelp()
+	case "allow", "deny":
+		printAllowHelp()
 	case "rules":
 		printRulesHelp()
 	case "config":
@@ -37,13 +52,24 @@ func printHelp() {
 https://github.com/mecha/tags
 
 USAGE:
-  %s [<COMMAND>] [<ARGUMENTS>] [<OPTIONS>]
+  %s [<OPTIONS>] [<COMMAND>] [<ARGUMENTS>]
 
 COMMANDS
   find          (Default) Output the tags for the given/current directory.
   show          Show all the tags and their rules. 
   add           Add new tags or rules.
   rm            Remove a tag or rule.
+  rename        Rename a tag.
+  history       Show the recent changes to the config.
+  undo          Revert the recent changes to the config.
+  root          Output the nearest ancestor directory that matches a tag.
+  mark          Add a tag to a directory's "user.xdg.tags" attribute.
+  unmark        Remove a tag from a directory's "user.xdg.tags" attribute.
+  tag           Manually assign tags to a directory.
+  untag         Remove manually assigned tags from a directory.
+  allow         Trust a project's local ".tags.json" file.
+  deny          Revoke the trust of a project's local ".tags.json" file.
+  config        Check, convert and migrate config files.
   help          Show this help message.
 
 OPTIONS
@@ -63,9 +89,11 @@ HELP PAGES
 }
 
 func printOptions() {
-	flag.VisitAll(func(f *flag.Flag) {
-		fmt.Printf("  -%s\t\t%s\n", f.Name, f.Usage)
-	})
+	flag.VisitAll(printOption)
+}
+
+func printOption(f *flag.Flag) {
+	fmt.Printf("  -%s\t\t%s\n", f.Name, f.Usage)
 }
 
 func printFindHelp() {
@@ -74,9 +102,13 @@ func printFindHelp() {
   Output the tags that match the given/current directory.
   This is the default command. The "find" may be omitted.
 
+  The output includes both the tags whose rules match, and the tags that were
+  manually assigned using "%[1]s tag". Use the "-s" option to show where each
+  tag came from.
+
 SYNOPSIS
 
-  %[1]s [find] [<DIRECTORY>] [<OPTIONS>]
+  %[1]s [<OPTIONS>] [find] [<DIRECTORY>]
 
 ARGUMENTS
 
@@ -94,18 +126,21 @@ EXAMPLES
 
   %[1]s
   %[1]s ~/Documents
-  %[1]s my/project -p
+  %[1]s -p my/project
+  %[1]s -s
 `, os.Args[0])
 }
 
 func printShowHelp() {
 	fmt.Printf(`DESCRIPTION
 
-  Shows all the tags and their rules.
+  Shows all the tags and their rules. Use the "-origin" option to also show
+  which config file each rule came from. Rules from included files and from
+  fragments show the file that they are written in.
 
 SYNOPSIS
 
-  %[1]s show [<OPTIONS>]
+  %[1]s [<OPTIONS>] show
 
 OPTIONS
 `, os.Args[0], configPath)
@@ -116,7 +151,8 @@ OPTIONS
 EXAMPLES
 
   %[1]s show
-  %[1]s show -c ~/backup-rules.json
+  %[1]s --origin show
+  %[1]s -c ~/backup-rules.json show
 `, os.Args[0])
 }
 
@@ -127,7 +163,7 @@ func printAddHelp() {
 
 SYNOPSIS
 
-  %[1]s add <TAG> <RULETYPE> <VALUES>... [<OPTIONS>]
+  %[1]s [<OPTIONS>] add <TAG> <RULETYPE> <VALUES>...
 
 ARGUMENTS
 
@@ -157,7 +193,7 @@ func printRmHelp() {
 
 SYNOPSIS
 
-  %[1]s rm <TAG> [<RULETYPE>] [<VALUES>...] [<OPTIONS>]
+  %[1]s [<OPTIONS>] rm <TAG> [<RULETYPE>] [<VALUES>...]
 
 ARGUMENTS
 
@@ -188,12 +224,254 @@ EXAMPLES
 `, os.Args[0])
 }
 
+func printRenameHelp() {
+	fmt.Printf(`DESCRIPTION
+
+  Renames a tag in the config file, keeping its rules, its directives, such as
+  "$use", its comments and its position in the file.
+
+SYNOPSIS
+
+  %[1]s [<OPTIONS>] rename <TAG> <NEWNAME>
+
+ARGUMENTS
+
+  <TAG>         The tag to rename.
+  <NEWNAME>     The new name of the tag. There must not be a tag with this name.
+
+OPTIONS
+
+`, os.Args[0])
+
+	printOptions()
+
+	fmt.Printf(`
+EXAMPLES
+
+  %[1]s rename go golang
+`, os.Args[0])
+}
+
+func printHistoryHelp() {
+	fmt.Printf(`DESCRIPTION
+
+  Shows or reverts the recent changes to the config file. Every change made by
+  commands such as "add", "rm", "rename" and "undo" is recorded in a history
+  file next to the config file, along with the time and the command that made
+  it.
+  The last %[2]d changes are kept.
+
+  The "history" command shows the changes, newest first, along with how they
+  changed the file. The "undo" command reverts the given number of changes.
+  An undo is also recorded as a change, so it can be undone in turn.
+
+  If the config file was changed by other means since the last recorded
+  change, such as in an editor, "undo" refuses to overwrite it.
+
+SYNOPSIS
+
+  %[1]s [<OPTIONS>] history [<COUNT>]
+  %[1]s [<OPTIONS>] undo [<COUNT>]
+
+ARGUMENTS
+
+  <COUNT>       The number of changes to show or to revert. Defaults to all
+                changes for "history", and to 1 for "undo".
+
+OPTIONS
+
+`, os.Args[0], config.MaxHistory)
+
+	printOptions()
+
+	fmt.Printf(`
+EXAMPLES
+
+  Show the last 3 changes:
+    %[1]s history 3
+
+  Revert the last 2 changes:
+    %[1]s undo 2
+`, os.Args[0])
+}
+
+func printRootHelp() {
+	fmt.Printf(`DESCRIPTION
+
+  Walks up from the given/current directory and outputs the path of the nearest
+  directory that matches a tag. If no directory matches, nothing is printed and
+  the exit status is 1.
+
+  Trusted local config files apply to each directory that is checked, as long
+  as the file is in that directory or in one of its parents. Their rules are
+  checked against each directory, and the tags that they list always match.
+
+SYNOPSIS
+
+  %[1]s [<OPTIONS>] root [-o] <EXPRESSION> [<DIRECTORY>]
+
+ARGUMENTS
+
+  <EXPRESSION>  The tag to look for, or a tag expression. Alternatives are
+                separated by ",", and tags that must all match are joined by
+                "+". Tags prefixed with "!" must not match.
+  <DIRECTORY>   The directory to start from. Defaults to the current directory.
+
+ROOT OPTIONS
+
+`, os.Args[0])
+
+	rootFlags.VisitAll(printOption)
+
+	fmt.Printf(`
+OPTIONS
+
+`)
+
+	printOptions()
+
+	fmt.Printf(`
+EXAMPLES
+
+  Find the root of the current Go project:
+    %[1]s root go
+
+  Find the outermost git repository:
+    %[1]s root -o git
+
+  Run the tests of the project that contains a file:
+    cd "$(%[1]s root go+make src/pkg)" && make test
+`, os.Args[0])
+}
+
+func printMarkHelp() {
+	fmt.Printf(`DESCRIPTION
+
+  Adds or removes a tag in a directory's "user.xdg.tags" extended attribute.
+  This is the attribute that file managers use for user-assigned tags, so the
+  tags are also visible in, and editable by, those file managers.
+
+  Marking a directory does not tag it by itself. The tag needs an "xattr" rule
+  that checks for it. See "%[1]s help rules" for more info.
+
+  Extended attributes are only supported on Linux.
+
+SYNOPSIS
+
+  %[1]s [<OPTIONS>] mark <TAG> [<DIRECTORY>]
+  %[1]s [<OPTIONS>] unmark <TAG> [<DIRECTORY>]
+
+ARGUMENTS
+
+  <TAG>         The tag to add or remove.
+  <DIRECTORY>   The directory to mark. Defaults to the current directory.
+
+OPTIONS
+
+`, os.Args[0])
+
+	printOptions()
+
+	fmt.Printf(`
+EXAMPLES
+
+  %[1]s add work xattr work
+  %[1]s mark work ~/projects/acme
+  %[1]s unmark work ~/projects/acme
+`, os.Args[0])
+}
+
+func printTagHelp() {
+	fmt.Printf(`DESCRIPTION
+
+  Manually assigns tags to a directory, or removes them. This is useful for
+  directories that cannot be detected by rules, such as client projects or
+  archived directories. Manually assigned tags are included in the output of
+  "%[1]s find", alongside the tags whose rules match.
+
+  The assignments are stored in a separate file from the config:
+  %[2]s
+
+  The "TAGS_MANUAL" environment variable can be used to change this path.
+
+SYNOPSIS
+
+  %[1]s [<OPTIONS>] tag <DIRECTORY> <TAG>...
+  %[1]s [<OPTIONS>] untag <DIRECTORY> [<TAG>...]
+
+ARGUMENTS
+
+  <DIRECTORY>   The directory to assign tags to, or remove tags from.
+  <TAG>...      The tags to assign or remove. If omitted when removing, all
+                of the directory's tags are removed.
+
+OPTIONS
+
+`, os.Args[0], manual.DefaultPath())
+
+	printOptions()
+
+	fmt.Printf(`
+EXAMPLES
+
+  Tag a directory and all of its subdirectories:
+    %[1]s -r tag ~/work/acme client-acme
+
+  Tag only a directory:
+    %[1]s tag ~/old-stuff archive
+
+  Remove all manual tags from a directory:
+    %[1]s untag ~/old-stuff
+`, os.Args[0])
+}
+
+func printAllowHelp() {
+	fmt.Printf(`DESCRIPTION
+
+  Trusts, or revokes the trust of, a project's local ".tags.json" file.
+
+  Local files are only applied once they are trusted, since they may come from
+  untrusted sources, such as cloned repositories. Trust is tied to the file's
+  contents, so a trusted file needs to be allowed again after it changes.
+
+  See "%[1]s help config" for the format of local files.
+
+SYNOPSIS
+
+  %[1]s [<OPTIONS>] allow [<PATH>]
+  %[1]s [<OPTIONS>] deny [<PATH>]
+
+ARGUMENTS
+
+  <PATH>        The local file, or the directory that contains it. Defaults to
+                the current directory.
+
+OPTIONS
+
+`, os.Args[0])
+
+	printOptions()
+
+	fmt.Printf(`
+EXAMPLES
+
+  %[1]s allow
+  %[1]s allow ~/projects/monorepo
+  %[1]s deny ~/projects/monorepo/.tags.json
+`, os.Args[0])
+}
+
 func printRulesHelp() {
 	fmt.Printf(`RULE TYPES
 
 This help page details the available tag rule types, how they match directories,
 and how to configure them.
 
+The paths in rules, such as the "files" of file_exists rules, may contain
+environment variables, such as "$HOME" or "${PROJECTS:-~/projects}", and may
+start with "~" or "~user". Other values, such as search text and patterns, are
+used as is. See "%[1]s help config" for details.
+
 ================================================================================
 file_exists
 
@@ -259,33 +537,485 @@ file_contains
 ================================================================================
 in_path
 
-  Rules with this type match directories that are in a specific path. The path
-  is checked using a prefix match, which means that the prefix path must be
-  absolute (either relative to the root or some other path token, such as "~"
-  or "%%APPDATA%%").
+  Rules with this type match directories that are in a specific path, or are
+  that path. Paths are compared segment by segment, so "/var/www" matches
+  "/var/www/site" but not "/var/www-old". The path must be absolute (either
+  relative to the root or some other path token, such as "~", "$XDG_DATA_HOME"
+  or "$APPDATA").
+
+  Paths may contain glob patterns, such as "~/clients/*". In globs, "*" does
+  not match "/", while "**" matches any number of nested directories.
 
-  Rules of this type take only one argument: the path to the directory.
+  Paths prefixed with "!" are exclusions: directories in these paths never
+  match, even if they are in one of the other paths.
+
+  Rules of this type take one or more paths as arguments.
 
   Add:        %[1]s add <TAG> in_path <PATH>
   Remove:     %[1]s rm <TAG> in_path <PATH>
 
   Examples:   %[1]s add fonts in_path /usr/share/fonts
-              %[1]s rm react file_contains package.json react
+              %[1]s add www in_path /var/www "!/var/www/old"
+              %[1]s rm fonts in_path /usr/share/fonts
 
-  In the config, these rules store the paths in a "paths" list. Example:
+  In the config, these rules store the paths in a "paths" list and the
+  exclusions in an "exclude" list. If "resolve_symlinks" is true, symlinks in
+  both the directory and the paths are resolved before comparing them.
+  Example:
 
   ┌─ rules.json ──────────────────────┐
   │ {                                 │
-  │   "fonts": {                      │
+  │   "www": {                        │
   │     "in_path": {                  │
   │       "paths": [                  │
-  │         "/usr/share/fonts"        │
+  │         "/var/www"                │
+  │       ],                          │
+  │       "exclude": [                │
+  │         "/var/www/old"            │
+  │       ],                          │
+  │       "resolve_symlinks": true    │
+  │     }                             │
+  │   }                               │
+  │ }                                 │
+  └───────────────────────────────────┘ 
+
+================================================================================
+env
+
+  Rules with this type match based on the environment that %[1]s runs in,
+  rather than the directory. A variable can be required to be set, to be equal
+  to a value, or to match a regular expression.
+
+  Rules of this type take one argument per variable: "NAME" checks that the
+  variable is set, "NAME=VALUE" checks for an exact value, and "NAME~REGEX"
+  checks that the value matches the expression.
+
+  Add:        %[1]s add <TAG> env <NAME>[=<VALUE>|~<REGEX>]
+  Remove:     %[1]s rm <TAG> env <NAME>[=<VALUE>|~<REGEX>]
+
+  Examples:   %[1]s add nix env IN_NIX_SHELL
+              %[1]s add tmux env TERM~^tmux
+              %[1]s rm nix env IN_NIX_SHELL
+
+  The variables are stored in "set", "equals" and "matches" in the config.
+  Example:
+
+  ┌─ rules.json ──────────────────────┐
+  │ {                                 │
+  │   "venv": {                       │
+  │     "env": {                      │
+  │       "set": ["VIRTUAL_ENV"],     │
+  │       "equals": {                 │
+  │         "CONDA_SHLVL": "1"        │
+  │       },                          │
+  │       "matches": {                │
+  │         "TERM": "^tmux"           │
+  │       }                           │
+  │     }                             │
+  │   }                               │
+  │ }                                 │
+  └───────────────────────────────────┘ 
+
+================================================================================
+executable
+
+  Rules with this type match when a command is available in the "$PATH". A
+  minimum version can optionally be required, in which case the command is run
+  with a flag (by default "--version") and the first version number found in
+  its output is compared against the minimum.
+
+  The results are cached while %[1]s runs, so many tags can check the same
+  command without slowing down shell prompts.
+
+  Rules of this type take one argument per command, optionally followed by a
+  minimum version.
+
+  Add:        %[1]s add <TAG> executable <COMMAND>[>=<VERSION>]
+  Remove:     %[1]s rm <TAG> executable <COMMAND>
+
+  Examples:   %[1]s add docker executable docker
+              %[1]s add compose executable "docker>=20.10"
+              %[1]s rm docker executable docker
+
+  The commands are stored in a "commands" list in the config. Version checks
+  are stored in a "versions" object, keyed by the command, where the "flag"
+  and the "pattern" regex used to extract the version may be customized.
+  Example:
+
+  ┌─ rules.json ──────────────────────────┐
+  │ {                                     │
+  │   "compose": {                        │
+  │     "executable": {                   │
+  │       "commands": ["docker"],         │
+  │       "versions": {                   │
+  │         "docker": {                   │
+  │           "flag": "version",          │
+  │           "pattern": "v([0-9.]+)",    │
+  │           "min": "20.10"              │
+  │         }                             │
+  │       }                               │
+  │     }                                 │
+  │   }                                   │
+  │ }                                     │
+  └───────────────────────────────────────┘ 
+
+================================================================================
+host, user, platform
+
+  Rules with these types match the machine that %[1]s runs on, rather than the
+  directory. This allows a single config file to be shared between machines,
+  while still having some tags only apply on certain machines.
+
+    host        Matches the machine's hostname (case-insensitive).
+    user        Matches the name of the current user.
+    platform    Matches the OS, written as "linux", "darwin", "windows", etc.,
+                or the OS and architecture, such as "linux/amd64".
+
+  All three take one or more patterns as arguments. Patterns may use the "*"
+  and "?" wildcards.
+
+  Add:        %[1]s add <TAG> host <PATTERN>
+              %[1]s add <TAG> user <PATTERN>
+              %[1]s add <TAG> platform <PATTERN>
+  Remove:     %[1]s rm <TAG> host <PATTERN>
+
+  Examples:   %[1]s add work host "*.corp.example.com"
+              %[1]s add admin user root
+              %[1]s add arm platform "*/arm64"
+
+  The patterns are stored in a "hosts", "users" or "platforms" list in the
+  config, respectively. Example:
+
+  ┌─ rules.json ──────────────────────┐
+  │ {                                 │
+  │   "ci": {                         │
+  │     "host": {                     │
+  │       "hosts": ["ci-*"]           │
+  │     }                             │
+  │   },                              │
+  │   "arm": {                        │
+  │     "platform": {                 │
+  │       "platforms": ["*/arm64"]    │
+  │     }                             │
+  │   }                               │
+  │ }                                 │
+  └───────────────────────────────────┘ 
+
+================================================================================
+path_match
+
+  Rules with this type match directories by their name, or by their full
+  absolute path, using glob patterns. Patterns prefixed with "re:" are treated
+  as regular expressions instead.
+
+  In globs, "*" and "?" match any characters except "/", "**" matches any
+  number of nested directories, and "{a,b}" matches either alternative.
+
+  Rules of this type take one or more patterns as arguments. Patterns that
+  contain a "/" are matched against the full path, and all other patterns are
+  matched against the directory's name.
+
+  Add:        %[1]s add <TAG> path_match <PATTERN>
+  Remove:     %[1]s rm <TAG> path_match <PATTERN>
+
+  Examples:   %[1]s add infra path_match "*-infra"
+              %[1]s add chart path_match "**/charts/*"
+              %[1]s add dotfiles path_match "re:^\.?dotfiles$"
+
+  Name patterns are stored in a "names" list in the config, and path patterns
+  in a "paths" list. Example:
+
+  ┌─ rules.json ──────────────────────┐
+  │ {                                 │
+  │   "infra": {                      │
+  │     "path_match": {               │
+  │       "names": ["*-infra"],       │
+  │       "paths": ["**/charts/*"]    │
+  │     }                             │
+  │   }                               │
+  │ }                                 │
+  └───────────────────────────────────┘ 
+
+================================================================================
+languages
+
+  Rules with this type match directories based on the languages of the files
+  in them, which is useful for projects without a manifest file. Files are
+  identified by their extension, or by the interpreter in their shebang line
+  (such as "#!/usr/bin/env bash") if they have no extension.
+
+  The walk is limited to a maximum depth and number of files, and skips
+  vendored directories (such as "node_modules", "vendor" and ".git"), the
+  patterns in the directory's ".gitignore" file and any "ignore" patterns.
+
+  A language matches if it has at least "min_files" files (default: 1), and
+  those files make up at least "min_percent" percent of all the files with a
+  known language (default: 0).
+
+  Rules of this type take one or more language names as arguments. Names are
+  case-insensitive, such as "Shell", "Python", "TeX" or "Go".
+
+  Add:        %[1]s add <TAG> languages <LANGUAGE>
+  Remove:     %[1]s rm <TAG> languages <LANGUAGE>
+
+  Examples:   %[1]s add scripts languages Shell
+              %[1]s add latex languages TeX
+
+  The language names are stored in a "languages" list in the config, along
+  with the optional "min_files", "min_percent", "max_depth" (default: 4),
+  "max_files" (default: 2000) and "ignore" settings. Example:
+
+  ┌─ rules.json ──────────────────────┐
+  │ {                                 │
+  │   "scripts": {                    │
+  │     "languages": {                │
+  │       "languages": ["Shell"],     │
+  │       "min_files": 3,             │
+  │       "min_percent": 50,          │
+  │       "max_depth": 2,             │
+  │       "ignore": ["build"]         │
+  │     }                             │
+  │   }                               │
+  │ }                                 │
+  └───────────────────────────────────┘ 
+
+================================================================================
+count
+
+  Rules with this type match directories where a count falls within a range.
+  The count is either the number of files and directories that match a glob
+  pattern ("glob"), or the total size in bytes of the files under a path
+  ("size_of").
+
+  The walk ends as soon as the result is known, such as when the count goes
+  over the maximum, to keep these rules fast in large directories. Globs are
+  only walked as deep as they have path segments, or 10 levels deep if they
+  use "**", unless "max_depth" is set. Globs skip vendored directories, such
+  as "node_modules" and ".git", and the directories in ".gitignore", unless
+  the glob names them explicitly, as in ".git/refs/heads/*". Globs that reach
+  them through "**", such as "**/package.json", do not look inside them.
+
+  Rules of this type take settings as <KEY>=<VALUE> arguments. The "min" and
+  "max" of sizes may use the K, M, G and T units.
+
+  Add:        %[1]s add <TAG> count glob=<GLOB> [min=<N>] [max=<N>]
+              %[1]s add <TAG> count size_of=<PATH> [min=<SIZE>] [max=<SIZE>]
+  Remove:     %[1]s rm <TAG> count [min|max|max_depth]
+
+  Examples:   %[1]s add many-migrations count "glob=migrations/*.sql" min=50
+              %[1]s add large-repo count size_of=. min=1G
+
+  The settings are stored as is in the config. Example:
+
+  ┌─ rules.json ──────────────────────┐
+  │ {                                 │
+  │   "large-repo": {                 │
+  │     "count": {                    │
+  │       "size_of": ".",             │
+  │       "min": "1G",                │
+  │       "max_depth": 8              │
+  │     }                             │
+  │   }                               │
+  │ }                                 │
+  └───────────────────────────────────┘ 
+
+================================================================================
+modified_within
+
+  Rules with this type match directories that have a file that was modified
+  within a duration, such as "7d". Durations may use the "s", "m", "h", "d"
+  (day) and "w" (week) units, and can be combined, as in "1d12h".
+
+  By default, all the files in the directory are checked, up to 3 levels deep
+  and up to 5000 files, skipping vendored directories. Alternatively, only
+  specific files or glob patterns can be checked, such as ".git/HEAD" or
+  "**/index.*". Patterns that name a vendored directory explicitly, such as
+  "node_modules/*/package.json", look inside it.
+
+  Rules of this type take a duration, followed by any files or patterns to
+  check.
+
+  Add:        %[1]s add <TAG> modified_within <DURATION> [<FILE>...]
+  Remove:     %[1]s rm <TAG> modified_within [<FILE>...]
+
+  Examples:   %[1]s add recent modified_within 7d
+              %[1]s add active modified_within 2w .git/HEAD
+
+  The duration is stored as "duration" in the config, and the files in an
+  optional "files" list. The walk limits can be changed using "max_depth" and
+  "max_files". Example:
+
+  ┌─ rules.json ──────────────────────┐
+  │ {                                 │
+  │   "recent": {                     │
+  │     "modified_within": {          │
+  │       "duration": "7d",           │
+  │       "files": [".git/HEAD"]      │
+  │     }                             │
+  │   }                               │
+  │ }                                 │
+  └───────────────────────────────────┘ 
+
+================================================================================
+file_type
+
+  Rules with this type match directories that have a file of a specific type.
+  The type is identified by the first bytes of the file, rather than by its
+  extension, and only those bytes are read.
+
+  Known types: elf, macho, pe, wasm, png, jpeg, gif, webp, pdf, sqlite, zip,
+  gzip, bzip2, xz, zstd, 7z and tar. The "script" type matches any file with
+  a shebang line, and "script:<INTERPRETER>" matches scripts that use a
+  specific interpreter, such as "script:python" for "#!/usr/bin/env python3".
+
+  The file may also be a glob pattern, in which case the rule matches if any
+  of the matching files has the type.
+
+  Rules of this type take two arguments: the file or pattern, and the type.
+
+  Add:        %[1]s add <TAG> file_type <FILE> <TYPE>
+  Remove:     %[1]s rm <TAG> file_type <FILE>
+
+  Examples:   %[1]s add binaries file_type "bin/*" elf
+              %[1]s add sqlite file_type "**/*.db" sqlite
+              %[1]s add py-tools file_type "*" script:python
+
+  The files and types are stored in a "types" object in the config. Example:
+
+  ┌─ rules.json ──────────────────────┐
+  │ {                                 │
+  │   "binaries": {                   │
+  │     "file_type": {                │
+  │       "types": {                  │
+  │         "bin/*": "elf"            │
+  │       }                           │
+  │     }                             │
+  │   }                               │
+  │ }                                 │
+  └───────────────────────────────────┘ 
+
+================================================================================
+license
+
+  Rules with this type match directories by the license of their contents.
+  Licenses are detected from license files (such as "LICENSE", "COPYING" or
+  "LICENSE-MIT"), by comparing their text with the known licenses, and from
+  the "license" field of "package.json", "composer.json", "Cargo.toml" and
+  "pyproject.toml" files.
+
+  Known licenses: MIT, ISC, BSD-2-Clause, BSD-3-Clause, Apache-2.0,
+  Unlicense, MPL-2.0, LGPL-2.1, LGPL-3.0, GPL-2.0, GPL-3.0 and AGPL-3.0.
+
+  Licenses are written as SPDX identifiers, and may use globs, such as
+  "GPL-*". Version suffixes are ignored, so "GPL-3.0" also matches projects
+  that use "GPL-3.0-or-later". The "permissive", "weak-copyleft" and
+  "copyleft" categories may also be used.
+
+  Rules of this type take one or more licenses or categories as arguments.
+
+  Add:        %[1]s add <TAG> license <LICENSE>
+  Remove:     %[1]s rm <TAG> license <LICENSE>
+
+  Examples:   %[1]s add gpl license "GPL-*"
+              %[1]s add permissive license permissive
+
+  The licenses are stored in a "licenses" list in the config. Example:
+
+  ┌─ rules.json ──────────────────────┐
+  │ {                                 │
+  │   "gpl": {                        │
+  │     "license": {                  │
+  │       "licenses": [               │
+  │         "GPL-*",                  │
+  │         "AGPL-*"                  │
   │       ]                           │
   │     }                             │
   │   }                               │
   │ }                                 │
   └───────────────────────────────────┘ 
 
+================================================================================
+access, owner, symlink, mount
+
+  Rules with these types match directories by their filesystem attributes.
+
+    access      Matches if the current user can, or cannot, read, write or
+                execute the directory. Takes "readable", "writable" and
+                "executable" as arguments, prefixed with "!" to negate them.
+                All of the checks must hold for the rule to match.
+    owner       Matches if the directory is owned by a user, or by a group
+                when prefixed with ":". Names or numeric IDs may be used.
+    symlink     Matches if any of the given files is a symbolic link. Use
+                "." to check the directory itself.
+    mount       Matches if the directory is on a filesystem of a given type,
+                such as "tmpfs", "nfs", "cifs" or "fuse". Types may use
+                globs. This rule type is only supported on Linux.
+
+  Add:        %[1]s add <TAG> access [!]<readable|writable|executable>
+              %[1]s add <TAG> owner <USER>|:<GROUP>
+              %[1]s add <TAG> symlink <FILE>
+              %[1]s add <TAG> mount <FSTYPE>
+  Remove:     %[1]s rm <TAG> owner <USER>|:<GROUP>
+
+  Examples:   %[1]s add readonly access "!writable"
+              %[1]s add system owner root
+              %[1]s add linked symlink .
+              %[1]s add remote mount nfs cifs smb2 fuse
+
+  In the config, "access" stores each check as a boolean, "owner" stores the
+  "users" and "groups" lists, "symlink" stores a "files" list and "mount"
+  stores an "fs_types" list. Example:
+
+  ┌─ rules.json ──────────────────────┐
+  │ {                                 │
+  │   "readonly": {                   │
+  │     "access": {                   │
+  │       "writable": false           │
+  │     }                             │
+  │   },                              │
+  │   "remote": {                     │
+  │     "mount": {                    │
+  │       "fs_types": ["nfs", "fuse"] │
+  │     }                             │
+  │   }                               │
+  │ }                                 │
+  └───────────────────────────────────┘ 
+
+================================================================================
+xattr
+
+  Rules with this type match directories by their extended attributes. Tags
+  are looked up in the "user.xdg.tags" attribute, which file managers use to
+  store user-assigned tags, and which can be set using "%[1]s mark". Other
+  "user.*" attributes can also be checked, either for being set or for having
+  a specific value. Extended attributes are only supported on Linux.
+
+  Rules of this type take tags, or attribute names that start with "user.",
+  optionally followed by "=<VALUE>".
+
+  Add:        %[1]s add <TAG> xattr <NAME>|<ATTR>[=<VALUE>]
+  Remove:     %[1]s rm <TAG> xattr <NAME>|<ATTR>
+
+  Examples:   %[1]s add work xattr work
+              %[1]s add acme xattr user.project=acme
+
+  Tags are stored in a "tags" list in the config, and attributes in an
+  "attrs" object, where an empty value only requires the attribute to be set.
+  Example:
+
+  ┌─ rules.json ──────────────────────┐
+  │ {                                 │
+  │   "work": {                       │
+  │     "xattr": {                    │
+  │       "tags": ["work"],           │
+  │       "attrs": {                  │
+  │         "user.project": ""        │
+  │       }                           │
+  │     }                             │
+  │   }                               │
+  │ }                                 │
+  └───────────────────────────────────┘ 
+
 ================================================================================
 MORE HELP
 
@@ -322,17 +1052,241 @@ func printConfigHelp() {
   
   See "%s help rules" for more information about the rule types.
 
+FORMATS
+
+  Config files may also be written in YAML, TOML or JSON with comments, using
+  the same structure. The format is detected from the file's extension:
+
+    .json, .jsonc     JSON, which may contain "//" and "/* */" comments and
+                      trailing commas.
+    .yaml, .yml       YAML.
+    .toml             TOML.
+
+  ┌─ rules.yaml ───────────────┐   ┌─ rules.toml ───────────────┐
+  │ # Go projects              │   │ # Go projects              │
+  │ go:                        │   │ [go.file_exists]           │
+  │   file_exists:             │   │ files = ["go.mod"]         │
+  │     files:                 │   │                            │
+  │       - go.mod             │   │                            │
+  └────────────────────────────┘   └────────────────────────────┘
+
+  If your main config file does not exist as "rules.json", the "rules.jsonc",
+  "rules.yaml", "rules.yml" and "rules.toml" files are looked for instead.
+
+  When %[2]s changes a config file, such as with the "add" and "rm" commands,
+  only the tags and rules that changed are written anew, at the indentation
+  of the entries around them, and in the same way as before: TOML rules stay
+  "[tag.rule]" tables, dotted keys or inline tables. New tags and rules are
+  written like the ones before them. The rest of the file is kept as it is,
+  along with its comments and the keys that are unknown to the rule types.
+
+  Comments inside a rule that is written anew are removed along with it, and
+  a warning names the rule. The same goes for tags that are written in ways
+  that cannot be changed rule by rule, such as a TOML tag that is written as
+  a single inline table.
+  The "undo" command restores files as they were, comments included. The
+  "config convert" command does not convert comments.
+
 MULTIPLE CONFIG FILES
 
-  When running %[2]s, your main config file will be used by default. You can
-  specify a different config file using the "-c" option. For example:
+  The config is made up of multiple layers, which are merged in this order:
+
+    1. The system-wide "tags/rules.json" files in $XDG_CONFIG_DIRS, such as
+       "/etc/xdg/tags/rules.json", and their "rules.d/*.json" drop-in files.
+    2. Your main config file.
+    3. The "rules.d/*.json" drop-in files next to your main config file.
+
+  Drop-in files are merged in lexical order, such as "10-go.json" before
+  "20-js.json". When multiple files have rules of the same type for the same
+  tag, the rules from the later file replace the earlier ones. Use
+  "%[2]s --origin show" to see which file each rule came from.
+
+  Commands that change the config, such as "add" and "rm", only change your
+  main config file, or the "-c" file. The file is replaced as a whole, so it
+  is never left half-written, and the previous %[3]d versions of it are kept
+  next to it as backups, from "rules.json.1.bak" (the newest) to
+  "rules.json.%[3]d.bak" (the oldest).
+
+  You can specify a different config file using the "-c" option, in which
+  case only that file is used. For example:
   
     %[2]s -c ~/backup-rules.json
 
   Alternatively, you can set the "TAGS_CONFIG" environment variable to the path
-  of the config file you want to use. For example:
+  of the config file you want to use as your main config file. For example:
 
     TAGS_CONFIG="~/backup-rules.json" %[2]s
 
-`, config.DefaultPath(), os.Args[0])
+INCLUDES AND FRAGMENTS
+
+  A config file can include other config files using the "$include" property,
+  and define reusable sets of rules, called fragments, using the "$fragments"
+  property. A tag, or another fragment, can use the rules of fragments by
+  listing their names in its "$use" property.
+
+  ┌─ rules.json ───────────────────────────────────────┐
+  │ {                                                  │
+  │   "$include": ["js.json", "work/*.json"],          │
+  │   "$fragments": {                                  │
+  │     "npm": {                                       │
+  │       "file_exists": { "files": ["package.json"] } │
+  │     }                                              │
+  │   },                                               │
+  │   "react": {                                       │
+  │     "$use": ["npm"],                               │
+  │     "file_contains": {                             │
+  │       "search": { "package.json": "react" }        │
+  │     }                                              │
+  │   }                                                │
+  │ }                                                  │
+  └────────────────────────────────────────────────────┘
+
+  Included paths are relative to the including file, and may contain globs.
+  The rules in a file replace the rules of the same type and tag from the
+  files it includes, and a tag's own rules replace the rules of the same type
+  from the fragments it uses. Fragments defined in included files can be used
+  in the including file.
+
+  Commands that change the config, such as "add" and "rm", keep these
+  properties, and do not copy the rules from included files or fragments.
+
+VARIABLES
+
+  The rule keys that hold paths, and the paths in "$include", are expanded
+  when the config is read:
+
+    $NAME, ${NAME}       The value of an environment variable.
+    ${NAME:-DEFAULT}     The value of a variable, or DEFAULT if the variable
+                         is unset or empty. DEFAULT is expanded too.
+    ~, ~/PATH            The current user's home directory.
+    ~USER, ~USER/PATH    Another user's home directory.
+    $$                   A literal "$".
+
+  The XDG base directory variables, such as "$XDG_DATA_HOME",
+  "$XDG_CONFIG_HOME", "$XDG_CACHE_HOME" and "$XDG_STATE_HOME", expand to their
+  default locations when they are not set. A "$" that is not followed by a
+  name or "{" is kept as is.
+
+  Only these rule keys are expanded. For the ones marked "(keys)", which map
+  paths to values, the paths are expanded and the values are used as is:
+
+%[4]s
+
+  Other keys, such as search text, are used as is. So are the values of "env"
+  rules, since they are compared with the values of variables as they are.
+  Regular expressions in "path_match" paths are expanded like the globs, so
+  write "$$" for a "$" that is followed by a name or "{".
+
+  Undefined variables expand to an empty string, and are reported as warnings
+  by "%[2]s config check". Use the "-strict" option to treat them as errors
+  instead. Commands that change the config keep the variables unexpanded.
+
+  ┌─ rules.json ──────────────────────────────────┐
+  │ {                                             │
+  │   "notes": {                                  │
+  │     "in_path": {                              │
+  │       "paths": ["${NOTES_DIR:-~/notes}"]      │
+  │     }                                         │
+  │   }                                           │
+  │ }                                             │
+  └───────────────────────────────────────────────┘
+
+VERSIONS
+
+  The "$version" property holds the version of a config file's format. It
+  starts with a "$", like the other directives, since the other top-level
+  properties are tags, and a tag may well be named "version". When the format
+  changes, older config files are upgraded to the latest version as they are
+  read, without changing the files. Files without a version are at version 0.
+
+    Version 1     Added the "$version" property.
+
+  Use "%[2]s config migrate" to upgrade a file permanently. Commands that
+  change a file, such as "add", upgrade the rules they write, and add the
+  "$version" property only if the file already has one or is upgraded.
+
+LOCAL CONFIG FILES
+
+  Projects can declare their own tags in a ".tags.json" file. The file applies
+  to the directory that contains it and all of its subdirectories, and files
+  in subdirectories take precedence over files in their parents.
+
+  ┌─ .tags.json ───────────────────────┐
+  │ {                                  │
+  │   "tags": ["monorepo"],            │
+  │   "suppress": ["node"],            │
+  │   "rules": {                       │
+  │     "service": {                   │
+  │       "file_exists": {             │
+  │         "files": ["Dockerfile"]    │
+  │       }                            │
+  │     }                              │
+  │   }                                │
+  │ }                                  │
+  └────────────────────────────────────┘
+
+  The "tags" always apply, the tags in "suppress" are removed from the main
+  config, and the "rules" are merged into the main config, using the same
+  format.
+
+  For safety, local files are ignored until they are trusted using the
+  "%[2]s allow" command. See "%[2]s help allow" for more info.
+
+CONFIG COMMANDS
+
+  %[2]s config convert [<SRC>] <DST>
+      Converts a config file to the format of the destination file, based on
+      its extension. The source defaults to your main config file.
+
+      Example: %[2]s config convert ~/.config/tags/rules.yaml
+
+  %[2]s config check [<FILE>...]
+      Checks config files and the files they include for problems, such as
+      unknown rule types, invalid rule configs and unknown keys, and reports
+      all of them with their line and column. Unknown keys are reported as
+      warnings. Exits with a non-zero status if any errors are found. The
+      files default to all of the config layers, or the "-c" file.
+
+      Example: %[2]s config check ~/.config/tags/rules.json
+
+  %[2]s config schema [<FILE>]
+      Prints a JSON Schema for config files, or writes it to a file. Editors
+      can use it to autocomplete and validate config files, including YAML
+      and TOML files in editors that support it. Like "config check", the
+      schema allows unknown keys in rules, but not unknown rule types.
+
+      Example: %[2]s config schema ~/.config/tags/schema.json
+
+  %[2]s config migrate [<FILE>]
+      Upgrades a config file to the latest version of the format. The original
+      file is kept next to it, with its version in the name, such as
+      "rules.json.v0.bak". The file defaults to your main config file.
+
+      Example: %[2]s config migrate ~/.config/tags/rules.yaml
+
+`, config.DefaultPath(), os.Args[0], config.MaxBackups, expandedKeys())
+}
+
+// expandedKeys lists the rule keys whose values, or whose keys for objects
+// that map paths to values, are expanded, one rule type per line.
+func expandedKeys() string {
+	lines := make([]string, 0)
+
+	for _, rType := range rules.Types() {
+		keys := make([]string, 0)
+
+		for _, field := range rules.Fields(rType) {
+			if field.Expand && field.Type == rules.MapField {
+				keys = append(keys, "\""+field.Name+"\" (keys)")
+			} else if field.Expand {
+				keys = append(keys, "\""+field.Name+"\"")
+			}
+		}
+
+		if len(keys) > 0 {
+			lines = append(lines, fmt.Sprintf("    %-20s %s", rType, strings.Join(keys, ", ")))
+		}
+	}
+
+	return strings.Join(lines, "\n")
 }
@@ -3,34 +3,57 @@ package main
 import (
 	"flag"
 	"fmt"
+	"maps"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/mecha/tags/config"
 	"github.com/mecha/tags/log"
+	"github.com/mecha/tags/manual"
+	"github.com/mecha/tags/rules"
 	tags "github.com/mecha/tags/tags"
+	"github.com/mecha/tags/utils"
 )
 
 var (
-	configPath string
-	directory  string
-	help       bool
-	parallel   bool
-	quiet      bool
-	verbose    bool
-	verbose2   bool
+	configPath  string
+	directory   string
+	help        bool
+	inherit     bool
+	outermost   bool
+	parallel    bool
+	quiet       bool
+	showOrigin  bool
+	showSources bool
+	verbose     bool
+	verbose2    bool
+
+	// rootFlags are the options of the "root" command, which come after the
+	// command, unlike the global options.
+	rootFlags = flag.NewFlagSet("root", flag.ExitOnError)
 )
 
 func main() {
 	flag.Usage = printHelp
 
 	flag.StringVar(&configPath, "c", config.DefaultPath(), "The path to the config file.")
+	flag.BoolVar(&inherit, "r", false, "Make tags assigned with \"tag\" apply to subdirectories.")
+	flag.BoolVar(&showOrigin, "origin", false, "Show which config file each rule came from with \"show\".")
 	flag.BoolVar(&parallel, "p", false, "Execute tag rules in parallel.")
 	flag.BoolVar(&quiet, "q", false, "Suppress all output.")
+	flag.BoolVar(&showSources, "s", false, "Show where each found tag came from.")
+	flag.BoolVar(&config.Strict, "strict", false, "Fail on undefined variables in config values.")
 	flag.BoolVar(&verbose, "v", false, "Show verbose output.")
 	flag.BoolVar(&verbose2, "vv", false, "Show debugging output.")
 	flag.Parse()
 
+	rootFlags.Usage = printRootHelp
+	rootFlags.BoolVar(&outermost, "o", false, "Find the outermost matching directory instead of the nearest.")
+
 	switch true {
 	case verbose2:
 		log.SetLevel(log.DebugLevel)
@@ -40,17 +63,6 @@ func main() {
 		log.SetLevel(log.QuietLevel)
 	}
 
-	log.Info("Reading config from %s\n", configPath)
-
-	cfg, err := config.Read(configPath)
-
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading config file. %s\n", err)
-		os.Exit(1)
-	}
-
-	log.Debug("Read %d tags\n", len(cfg))
-
 	command := ""
 	args := flag.Args()
 
@@ -60,27 +72,182 @@ func main() {
 
 	switch command {
 	case "help":
-        helpCommand(args[1:])
+		helpCommand(args[1:])
 	case "add":
 		log.Debug("Running `add` command\n")
-        addCommand(cfg, args[1:])
+		lockConfig()
+		addCommand(readEditableConfig(), args[1:])
 	case "rm":
 		log.Debug("Running `rm` command\n")
-        rmCommand(cfg, args[1:])
+		lockConfig()
+		rmCommand(readEditableConfig(), args[1:])
+	case "rename":
+		log.Debug("Running `rename` command\n")
+		lockConfig()
+		renameCommand(readEditableConfig(), args[1:])
+	case "history":
+		log.Debug("Running `history` command\n")
+		historyCommand(args[1:])
+	case "undo":
+		log.Debug("Running `undo` command\n")
+		undoCommand(args[1:])
 	case "show":
 		log.Debug("Running `show` command\n")
-		showCommand(cfg)
+		showCommand(readConfig())
+	case "root":
+		log.Debug("Running `root` command\n")
+		cfg, _ := readConfig()
+		rootCommand(cfg, args[1:])
+	case "mark":
+		log.Debug("Running `mark` command\n")
+		cfg, _ := readConfig()
+		markCommand(cfg, args[1:], true)
+	case "unmark":
+		log.Debug("Running `unmark` command\n")
+		cfg, _ := readConfig()
+		markCommand(cfg, args[1:], false)
+	case "tag":
+		log.Debug("Running `tag` command\n")
+		tagCommand(args[1:])
+	case "untag":
+		log.Debug("Running `untag` command\n")
+		untagCommand(args[1:])
+	case "allow":
+		log.Debug("Running `allow` command\n")
+		trustCommand(args[1:], true)
+	case "deny":
+		log.Debug("Running `deny` command\n")
+		trustCommand(args[1:], false)
+	case "config":
+		log.Debug("Running `config` command\n")
+		configCommand(args[1:])
 	case "find":
-        args = args[1:]
-        fallthrough
-    default:
+		args = args[1:]
+		fallthrough
+	default:
 		log.Debug("Running `find` command\n")
+		cfg, _ := readConfig()
 		findCommand(cfg, args)
 	}
 
 	os.Exit(0)
 }
 
+// readConfig reads the config that is used to find tags. This is the layered
+// config, unless a config file was given using the "-c" option.
+func readConfig() (map[string]tags.Tag, config.Origins) {
+	paths := config.LayerPaths()
+
+	if isFlagSet("c") {
+		if _, err := os.Stat(configPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading config file. %s\n", err)
+			os.Exit(1)
+		}
+
+		paths = []string{configPath}
+	}
+
+	for _, path := range paths {
+		log.Info("Reading config from %s\n", path)
+	}
+
+	cfg, origins, err := config.ReadLayers(paths)
+
+	if err != nil {
+		exitWithConfigError(paths, err)
+	}
+
+	log.Debug("Read %d tags\n", len(cfg))
+
+	return cfg, origins
+}
+
+// lockConfig locks the main config file until the process exits, so that
+// commands that change it do not overwrite each other's changes.
+func lockConfig() {
+	if _, err := config.Lock(configPath); err != nil {
+		log.Error("%s\n", err)
+		os.Exit(1)
+	}
+}
+
+// lockManual locks the manual tag database until the process exits, so that
+// commands that change it do not overwrite each other's changes.
+func lockManual(path string) {
+	if _, err := config.Lock(path); err != nil {
+		log.Error("%s\n", err)
+		os.Exit(1)
+	}
+}
+
+// readEditableConfig reads only the config file that commands which change
+// the config should write to, so that the tags from other layers are not
+// copied into it. A missing file is treated as an empty config.
+func readEditableConfig() map[string]tags.Tag {
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		log.Info("Config file %s does not exist yet\n", configPath)
+		return make(map[string]tags.Tag)
+	}
+
+	log.Info("Reading config from %s\n", configPath)
+
+	cfg, err := config.ReadFile(configPath)
+
+	if err != nil {
+		exitWithConfigError([]string{configPath}, err)
+	}
+
+	return cfg
+}
+
+// exitWithConfigError reports an error from reading the config and exits. The
+// config files are checked to report all of their problems at once, with
+// their locations, falling back to the error itself.
+func exitWithConfigError(paths []string, err error) {
+	problems := config.Check(existingPaths(paths))
+
+	if !config.HasErrors(problems) {
+		fmt.Fprintf(os.Stderr, "Error reading config file. %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "Error reading config file.\n")
+
+	for _, problem := range problems {
+		if !problem.Warning {
+			fmt.Fprintf(os.Stderr, "%s\n", problem)
+		}
+	}
+
+	os.Exit(1)
+}
+
+// existingPaths returns the paths of the files that exist.
+func existingPaths(paths []string) []string {
+	result := make([]string, 0, len(paths))
+
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			result = append(result, path)
+		}
+	}
+
+	return result
+}
+
+// isFlagSet reports whether an option was given on the command line.
+func isFlagSet(name string) bool {
+	found := false
+
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+
+	return found
+}
+
 func findCommand(cfg map[string]tags.Tag, args []string) {
 	dir := ""
 
@@ -98,6 +265,27 @@ func findCommand(cfg map[string]tags.Tag, args []string) {
 
 	log.Info("Directory = %s\n", dir)
 
+	sources := make(map[string][]string)
+	mutex := sync.Mutex{}
+
+	addMatch := func(name string, source string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		sources[name] = append(sources[name], source)
+	}
+
+	log.Info("Checking local config files\n")
+
+	if trust, err := config.ReadTrust(config.TrustPath()); err != nil {
+		log.Error("Error reading trusted files. %s\n", err)
+	} else if localTags, err := config.ApplyLocal(dir, cfg, trust); err != nil {
+		log.Error("%s\n", err)
+	} else {
+		for _, name := range localTags {
+			addMatch(name, "local")
+		}
+	}
+
 	if parallel {
 		log.Info("Checking tag rules in parallel\n")
 		wg := sync.WaitGroup{}
@@ -110,7 +298,7 @@ func findCommand(cfg map[string]tags.Tag, args []string) {
 				log.Debug("=> %s\n", name)
 
 				if tags.IsMatch(dir, &tag) {
-					fmt.Println(name)
+					addMatch(name, "rules")
 				}
 			}(tagName, tag)
 		}
@@ -123,11 +311,105 @@ func findCommand(cfg map[string]tags.Tag, args []string) {
 			log.Debug("=> %s\n", tagName)
 
 			if tags.IsMatch(dir, &tag) {
-				fmt.Println(tagName)
+				addMatch(tagName, "rules")
 			}
 		}
+	}
+
+	log.Info("Checking manually assigned tags\n")
+
+	if db, err := manual.Read(manual.DefaultPath()); err != nil {
+		log.Error("Error reading manual tags. %s\n", err)
+	} else if manualTags, err := db.Lookup(dir); err != nil {
+		log.Error("%s\n", err)
+	} else {
+		for _, name := range manualTags {
+			addMatch(name, "manual")
+		}
+	}
+
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if showSources {
+			fmt.Printf("%s\t%s\n", name, strings.Join(sources[name], ","))
+		} else {
+			fmt.Println(name)
+		}
+	}
+
+	log.Debug("Done\n")
+
+	os.Exit(0)
+}
+
+func tagCommand(args []string) {
+	switch len(args) {
+	case 0:
+		log.Error("No directory specified.\n")
+		os.Exit(1)
+	case 1:
+		log.Error("No tag specified.\n")
+		os.Exit(1)
+	}
+
+	dbPath := manual.DefaultPath()
+	lockManual(dbPath)
 
-		log.Debug("Done\n")
+	db, err := manual.Read(dbPath)
+	if err != nil {
+		log.Error("%s\n", err)
+		os.Exit(1)
+	}
+
+	for _, tagName := range args[1:] {
+		log.Info("Tagging %s with \"%s\"\n", args[0], tagName)
+
+		if err = db.Add(args[0], tagName, inherit); err != nil {
+			log.Error("%s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if err = manual.Write(dbPath, db); err != nil {
+		log.Error("%s\n", err)
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
+
+func untagCommand(args []string) {
+	if len(args) == 0 {
+		log.Error("No directory specified.\n")
+		os.Exit(1)
+	}
+
+	dbPath := manual.DefaultPath()
+	lockManual(dbPath)
+
+	db, err := manual.Read(dbPath)
+	if err != nil {
+		log.Error("%s\n", err)
+		os.Exit(1)
+	}
+
+	count, err := db.Remove(args[0], args[1:])
+	if err != nil {
+		log.Error("%s\n", err)
+		os.Exit(1)
+	}
+
+	log.Info("Removed %d tags from %s\n", count, args[0])
+
+	if err = manual.Write(dbPath, db); err != nil {
+		log.Error("%s\n", err)
+		os.Exit(1)
 	}
 
 	os.Exit(0)
@@ -168,7 +450,7 @@ func addCommand(cfg map[string]tags.Tag, args []string) {
 	}
 
 	cfg[tagName] = tag
-	err = config.Write(configPath, cfg)
+	err = config.Write(configPath, cfg, "add "+strings.Join(args, " "))
 
 	if err != nil {
 		log.Error("%s\n", err)
@@ -178,13 +460,22 @@ func addCommand(cfg map[string]tags.Tag, args []string) {
 	os.Exit(0)
 }
 
-func showCommand(cfg map[string]tags.Tag) {
+func showCommand(cfg map[string]tags.Tag, origins config.Origins) {
 	for tagName, tag := range cfg {
 		fmt.Printf("[%s]\n", tagName)
 
 		if len(tag.Rules) > 0 {
 			for _, rule := range tag.Rules {
-				fmt.Println(rule)
+				if !showOrigin {
+					fmt.Println(rule)
+					continue
+				}
+
+				origin := origins[tagName][rules.GetType(rule)]
+
+				for _, line := range strings.Split(rule.String(), "\n") {
+					fmt.Printf("%s\t(%s)\n", line, origin)
+				}
 			}
 
 			fmt.Println()
@@ -226,7 +517,7 @@ func rmCommand(cfg map[string]tags.Tag, args []string) {
 		cfg[tagName] = tag
 	}
 
-	err := config.Write(configPath, cfg)
+	err := config.Write(configPath, cfg, "rm "+strings.Join(args, " "))
 
 	if err != nil {
 		log.Error("%s\n", err)
@@ -235,3 +526,376 @@ func rmCommand(cfg map[string]tags.Tag, args []string) {
 
 	os.Exit(0)
 }
+
+func renameCommand(cfg map[string]tags.Tag, args []string) {
+	if len(args) < 2 {
+		log.Error("Expected the tag to rename and its new name.\n")
+		os.Exit(1)
+	}
+
+	oldName, newName := args[0], args[1]
+
+	if _, ok := cfg[oldName]; !ok {
+		log.Error("Tag \"%s\" not found\n", oldName)
+		os.Exit(1)
+	}
+
+	if _, exists := cfg[newName]; exists {
+		log.Error("Tag \"%s\" already exists\n", newName)
+		os.Exit(1)
+	}
+
+	log.Info("Renaming tag \"%s\" to \"%s\"\n", oldName, newName)
+
+	if err := config.Rename(configPath, oldName, newName, "rename "+oldName+" "+newName); err != nil {
+		log.Error("%s\n", err)
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
+
+func historyCommand(args []string) {
+	history, err := config.ReadHistory(configPath)
+	if err != nil {
+		log.Error("%s\n", err)
+		os.Exit(1)
+	}
+
+	limit := len(history)
+	if len(args) > 0 {
+		if limit, err = strconv.Atoi(args[0]); err != nil || limit < 1 {
+			log.Error("Invalid number of changes: %s\n", args[0])
+			os.Exit(1)
+		}
+	}
+
+	for n := 1; n <= limit && n <= len(history); n++ {
+		change := history[len(history)-n]
+
+		fmt.Printf("%d  %s  %s\n", n, change.Time.Local().Format("2006-01-02 15:04:05"), change.Command)
+
+		for _, line := range utils.DiffLines(change.Before, change.After, 2) {
+			fmt.Printf("    %s\n", line)
+		}
+
+		fmt.Println()
+	}
+}
+
+func undoCommand(args []string) {
+	n := 1
+
+	if len(args) > 0 {
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+			log.Error("Invalid number of changes: %s\n", args[0])
+			os.Exit(1)
+		}
+	}
+
+	change, err := config.Undo(configPath, n)
+	if err != nil {
+		log.Error("%s\n", err)
+		os.Exit(1)
+	}
+
+	log.Info("Reverted %s to before \"%s\" (%s)\n", configPath, change.Command, change.Time.Local().Format("2006-01-02 15:04:05"))
+}
+
+func rootCommand(cfg map[string]tags.Tag, args []string) {
+	rootFlags.Parse(args)
+	args = rootFlags.Args()
+
+	if len(args) == 0 {
+		log.Error("No tag specified.\n")
+		os.Exit(1)
+	}
+
+	var err error
+
+	dir := ""
+	if len(args) > 1 {
+		dir = args[1]
+	} else if dir, err = os.Getwd(); err != nil {
+		log.Error("%s\n", err)
+		os.Exit(1)
+	}
+
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		log.Error("%s\n", err)
+		os.Exit(1)
+	}
+
+	log.Info("Checking local config files\n")
+
+	// The local config files of the directory also apply to the parents
+	// that they are in, and their tags can be used in the expression.
+	local := config.LocalFiles{}
+
+	if trust, err := config.ReadTrust(config.TrustPath()); err != nil {
+		log.Error("Error reading trusted files. %s\n", err)
+	} else if local, err = config.ReadLocalFiles(dir, trust); err != nil {
+		log.Error("%s\n", err)
+	}
+
+	known := maps.Clone(cfg)
+	for _, name := range local.Apply(dir, known) {
+		if _, ok := known[name]; !ok {
+			known[name] = tags.Tag{}
+		}
+	}
+
+	expr, err := tags.ParseExpr(args[0], known)
+	if err != nil {
+		log.Error("%s\n", err)
+		os.Exit(1)
+	}
+
+	found := ""
+
+	for _, parent := range utils.Ancestors(dir) {
+		log.Debug("=> %s\n", parent)
+
+		parentCfg := maps.Clone(cfg)
+		always := local.Apply(parent, parentCfg)
+
+		if expr.Match(parent, parentCfg, always) {
+			found = parent
+
+			if !outermost {
+				break
+			}
+		}
+	}
+
+	if found == "" {
+		log.Info("No matching directory found\n")
+		os.Exit(1)
+	}
+
+	fmt.Println(found)
+	os.Exit(0)
+}
+
+func markCommand(cfg map[string]tags.Tag, args []string, mark bool) {
+	if len(args) == 0 {
+		log.Error("No tag specified.\n")
+		os.Exit(1)
+	}
+
+	tagName := args[0]
+	dir := "."
+	if len(args) > 1 {
+		dir = args[1]
+	}
+
+	current, err := utils.ReadXDGTags(dir)
+	if err != nil {
+		log.Error("%s\n", err)
+		os.Exit(1)
+	}
+
+	updated := make([]string, 0, len(current)+1)
+	for _, name := range current {
+		if name != tagName {
+			updated = append(updated, name)
+		}
+	}
+
+	if mark {
+		updated = append(updated, tagName)
+	}
+
+	log.Info("Setting %s on %s: %v\n", utils.XDGTagsAttr, dir, updated)
+
+	if err = utils.WriteXDGTags(dir, updated); err != nil {
+		log.Error("%s\n", err)
+		os.Exit(1)
+	}
+
+	if mark && !hasXattrRule(cfg, tagName) {
+		log.Info("Note: no \"%[1]s\" tag has an xattr rule for it. Add one using:\n  %[2]s add %[1]s xattr %[1]s\n", tagName, os.Args[0])
+	}
+
+	os.Exit(0)
+}
+
+// hasXattrRule reports whether a tag has an xattr rule that checks for it.
+func hasXattrRule(cfg map[string]tags.Tag, tagName string) bool {
+	tag, ok := cfg[tagName]
+	if !ok {
+		return false
+	}
+
+	for _, rule := range tag.Rules {
+		if xattr, ok := rule.(*rules.Xattr); ok {
+			for _, name := range xattr.Tags {
+				if name == tagName {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+func trustCommand(args []string, allow bool) {
+	path := "."
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, config.LocalFileName)
+	}
+
+	trustPath := config.TrustPath()
+
+	trust, err := config.ReadTrust(trustPath)
+	if err != nil {
+		log.Error("%s\n", err)
+		os.Exit(1)
+	}
+
+	if allow {
+		_, raw, err := config.ReadLocal(path)
+		if err != nil {
+			log.Error("%s\n", err)
+			os.Exit(1)
+		}
+
+		log.Info("Allowing %s\n", path)
+		err = trust.Allow(path, raw)
+	} else {
+		log.Info("Denying %s\n", path)
+		err = trust.Deny(path)
+	}
+
+	if err != nil {
+		log.Error("%s\n", err)
+		os.Exit(1)
+	}
+
+	if err = config.WriteTrust(trustPath, trust); err != nil {
+		log.Error("%s\n", err)
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
+
+func configCommand(args []string) {
+	if len(args) == 0 {
+		log.Error("No config command specified. See \"%s help config\".\n", os.Args[0])
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "convert":
+		configConvertCommand(args[1:])
+	case "check":
+		configCheckCommand(args[1:])
+	case "schema":
+		configSchemaCommand(args[1:])
+	case "migrate":
+		configMigrateCommand(args[1:])
+	default:
+		log.Error("Unknown config command \"%s\". See \"%s help config\".\n", args[0], os.Args[0])
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
+
+func configConvertCommand(args []string) {
+	src, dst := configPath, ""
+
+	switch len(args) {
+	case 0:
+		log.Error("No destination file specified.\n")
+		os.Exit(1)
+	case 1:
+		dst = args[0]
+	default:
+		src, dst = args[0], args[1]
+	}
+
+	log.Info("Converting %s to %s\n", src, dst)
+
+	if err := config.Convert(src, dst); err != nil {
+		log.Error("%s\n", err)
+		os.Exit(1)
+	}
+}
+
+func configCheckCommand(args []string) {
+	paths := args
+
+	if len(paths) == 0 {
+		paths = []string{configPath}
+
+		if !isFlagSet("c") {
+			paths = existingPaths(config.LayerPaths())
+		}
+	}
+
+	problems := config.Check(paths)
+	nErrors := 0
+
+	for _, problem := range problems {
+		fmt.Println(problem)
+
+		if !problem.Warning {
+			nErrors++
+		}
+	}
+
+	log.Info("Checked %d files: %d errors, %d warnings\n", len(paths), nErrors, len(problems)-nErrors)
+
+	if nErrors > 0 {
+		os.Exit(1)
+	}
+}
+
+func configSchemaCommand(args []string) {
+	schema, err := config.SchemaJSON()
+	if err != nil {
+		log.Error("%s\n", err)
+		os.Exit(1)
+	}
+
+	if len(args) == 0 {
+		fmt.Println(string(schema))
+		return
+	}
+
+	log.Info("Writing schema to %s\n", args[0])
+
+	if err := os.WriteFile(args[0], append(schema, '\n'), 0644); err != nil {
+		log.Error("%s\n", err)
+		os.Exit(1)
+	}
+}
+
+func configMigrateCommand(args []string) {
+	path := configPath
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	from, backup, err := config.Migrate(path)
+	if err != nil {
+		log.Error("%s\n", err)
+		os.Exit(1)
+	}
+
+	if backup == "" {
+		log.Info("%s is already at version %d\n", path, from)
+		return
+	}
+
+	log.Info("Migrated %s from version %d to %d. The original file was saved to %s\n", path, from, config.LatestVersion(), backup)
+}
@@ -0,0 +1,144 @@
+package manual
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/OpenPeeDeeP/xdg"
+	"github.com/mecha/tags/config"
+	"github.com/mecha/tags/utils"
+)
+
+type (
+	// DB stores tags that were manually assigned to directories, keyed by
+	// the directories' absolute paths and then by tag name.
+	DB map[string]map[string]Entry
+
+	Entry struct {
+		// Inherit makes the tag also apply to all subdirectories.
+		Inherit bool `json:"inherit,omitempty"`
+	}
+)
+
+func DefaultPath() string {
+	defPath := xdg.DataHome() + "/tags/manual.json"
+
+	envPath, ok := os.LookupEnv("TAGS_MANUAL")
+	if !ok {
+		return defPath
+	}
+
+	expPath, err := utils.Expand(envPath)
+	if err != nil {
+		return defPath
+	}
+
+	return expPath
+}
+
+// Read reads a database file. A missing file is treated as an empty database.
+func Read(path string) (DB, error) {
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return DB{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	db := DB{}
+	if err = json.Unmarshal(raw, &db); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// Write replaces a database file atomically, while holding its lock. Callers
+// that read the database before changing it should lock it first with
+// config.Lock, so that concurrent changes are not lost.
+func Write(path string, db DB) error {
+	str, err := json.MarshalIndent(db, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return config.WriteFile(path, str, 0644)
+}
+
+// Add assigns a tag to a directory, replacing any previous assignment of the
+// same tag to the same directory.
+func (db DB) Add(dir string, tag string, inherit bool) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	if db[abs] == nil {
+		db[abs] = make(map[string]Entry)
+	}
+
+	db[abs][tag] = Entry{Inherit: inherit}
+
+	return nil
+}
+
+// Remove removes tags from a directory, or all of its tags if none are
+// given. It returns the number of tags that were removed.
+func (db DB) Remove(dir string, tags []string) (int, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	entries := db[abs]
+	count := 0
+
+	if len(tags) == 0 {
+		count = len(entries)
+		delete(db, abs)
+		return count, nil
+	}
+
+	for _, tag := range tags {
+		if _, ok := entries[tag]; ok {
+			delete(entries, tag)
+			count++
+		}
+	}
+
+	if len(entries) == 0 {
+		delete(db, abs)
+	}
+
+	return count, nil
+}
+
+// Lookup returns the tags of a directory, including the inherited tags of
+// its parent directories, in lexical order.
+func (db DB) Lookup(dir string) ([]string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]bool)
+
+	for i, parent := range utils.Ancestors(abs) {
+		for tag, entry := range db[parent] {
+			if i == 0 || entry.Inherit {
+				found[tag] = true
+			}
+		}
+	}
+
+	result := make([]string, 0, len(found))
+	for tag := range found {
+		result = append(result, tag)
+	}
+
+	sort.Strings(result)
+
+	return result, nil
+}
@@ -0,0 +1,136 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mecha/tags/log"
+)
+
+type (
+	// Access matches directories that the current user can or cannot read,
+	// write or execute. The keys of Checks are "readable", "writable" and
+	// "executable", and all of the checks must hold for the rule to match.
+	Access struct {
+		Checks map[string]bool
+	}
+)
+
+func init() {
+	register("access", "Matches on whether the directory can be accessed by the current user.", func() Rule { return &Access{} },
+		Field{Name: "readable", Type: BoolField, Description: "Whether the directory must be readable."},
+		Field{Name: "writable", Type: BoolField, Description: "Whether the directory must be writable."},
+		Field{Name: "executable", Type: BoolField, Description: "Whether the directory must be executable."},
+	)
+}
+
+var accessModes = map[string]uint32{
+	"readable":   accessRead,
+	"writable":   accessWrite,
+	"executable": accessExecute,
+}
+
+func (r *Access) Load(cfg map[string]interface{}) error {
+	r.Checks = make(map[string]bool)
+
+	for key := range accessModes {
+		val, ok := cfg[key]
+		if !ok {
+			continue
+		}
+
+		expected, ok := val.(bool)
+		if !ok {
+			return fmt.Errorf("[access] \"%s\" is not a boolean: %v", key, val)
+		}
+
+		r.Checks[key] = expected
+	}
+
+	if len(r.Checks) == 0 {
+		return fmt.Errorf("[access] config needs at least one of \"readable\", \"writable\" or \"executable\"\n")
+	}
+
+	return nil
+}
+
+func (r *Access) GetConfig() map[string]interface{} {
+	cfg := make(map[string]interface{}, len(r.Checks))
+
+	for key, expected := range r.Checks {
+		cfg[key] = expected
+	}
+
+	return cfg
+}
+
+func (r *Access) Evaluate(dir string) (bool, error) {
+	if len(r.Checks) == 0 {
+		return false, nil
+	}
+
+	for key, expected := range r.Checks {
+		actual := canAccess(dir, accessModes[key])
+		log.Debug("   [access] %s: %t\n", key, actual)
+
+		if actual != expected {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// Checks prefixed with "!" must not hold.
+//
+// tags add readonly access "!writable"
+func (r *Access) Add(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("No checks specified.")
+	}
+
+	if r.Checks == nil {
+		r.Checks = make(map[string]bool)
+	}
+
+	for _, arg := range args {
+		key := strings.TrimPrefix(arg, "!")
+
+		if _, ok := accessModes[key]; !ok {
+			return fmt.Errorf("Unknown check \"%s\". Expected readable, writable or executable.", key)
+		}
+
+		r.Checks[key] = key == arg
+	}
+
+	return nil
+}
+
+func (r *Access) Del(args []string) error {
+	if len(args) == 0 {
+		r.Checks = make(map[string]bool)
+		return nil
+	}
+
+	for _, arg := range args {
+		delete(r.Checks, strings.TrimPrefix(arg, "!"))
+	}
+
+	return nil
+}
+
+func (r *Access) String() string {
+	checks := make([]string, 0, len(r.Checks))
+
+	for _, key := range []string{"readable", "writable", "executable"} {
+		if expected, ok := r.Checks[key]; ok {
+			if expected {
+				checks = append(checks, key)
+			} else {
+				checks = append(checks, "!"+key)
+			}
+		}
+	}
+
+	return fmt.Sprintf("[access] %s", strings.Join(checks, ", "))
+}
@@ -0,0 +1,303 @@
+package rules
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mecha/tags/log"
+	"github.com/mecha/tags/utils"
+)
+
+type (
+	// Count matches directories where either the number of entries that
+	// match Glob, or the total size of the files under SizeOf, is within
+	// the Min and Max range. A Max of zero means there is no maximum.
+	Count struct {
+		Glob     string
+		SizeOf   string
+		Min      int64
+		Max      int64
+		MaxDepth int
+	}
+)
+
+func init() {
+	register("count", "Matches on the number of files matching a glob, or the size of a path.", func() Rule { return &Count{} },
+		Field{Name: "glob", Type: StringField, Description: "The glob of the files to count.", Expand: true},
+		Field{Name: "size_of", Type: StringField, Description: "The path to measure the total size of.", Expand: true},
+		Field{Name: "min", Type: SizeField, Description: "The minimum count or size."},
+		Field{Name: "max", Type: SizeField, Description: "The maximum count or size."},
+		Field{Name: "max_depth", Type: IntField, Description: "How many directories deep to look."},
+	)
+}
+
+// The default depth for globs that use "**". Other globs are only walked as
+// deep as they have path segments.
+const defaultCountMaxDepth = 10
+
+func (r *Count) Load(cfg map[string]interface{}) error {
+	for key, ptr := range map[string]*string{"glob": &r.Glob, "size_of": &r.SizeOf} {
+		if val, ok := cfg[key]; ok {
+			if *ptr, ok = val.(string); !ok {
+				return fmt.Errorf("[count] \"%s\" is not a string: %v", key, val)
+			}
+		}
+	}
+
+	if (r.Glob == "") == (r.SizeOf == "") {
+		return fmt.Errorf("[count] config needs exactly one of \"glob\" or \"size_of\"\n")
+	}
+
+	if r.Glob != "" {
+		if _, err := utils.CompileGlob(r.Glob); err != nil {
+			return fmt.Errorf("[count] invalid glob \"%s\": %s", r.Glob, err)
+		}
+	}
+
+	for key, ptr := range map[string]*int64{"min": &r.Min, "max": &r.Max} {
+		val, ok := cfg[key]
+		if !ok {
+			continue
+		}
+
+		switch val := val.(type) {
+		case float64:
+			*ptr = int64(val)
+		case string:
+			size, err := utils.ParseSize(val)
+			if err != nil {
+				return fmt.Errorf("[count] invalid \"%s\": %s", key, err)
+			}
+			*ptr = size
+		default:
+			return fmt.Errorf("[count] \"%s\" is not a number or size: %v", key, val)
+		}
+	}
+
+	return loadInt("count", "max_depth", cfg, &r.MaxDepth)
+}
+
+func (r *Count) GetConfig() map[string]interface{} {
+	cfg := map[string]interface{}{}
+
+	if r.Glob != "" {
+		cfg["glob"] = r.Glob
+	}
+	if r.SizeOf != "" {
+		cfg["size_of"] = r.SizeOf
+	}
+
+	for key, val := range map[string]int64{"min": r.Min, "max": r.Max} {
+		if val <= 0 {
+			continue
+		}
+
+		if r.SizeOf != "" {
+			cfg[key] = utils.FormatSize(val)
+		} else {
+			cfg[key] = val
+		}
+	}
+
+	if r.MaxDepth > 0 {
+		cfg["max_depth"] = r.MaxDepth
+	}
+
+	return cfg
+}
+
+func (r *Count) Evaluate(dir string) (bool, error) {
+	if r.SizeOf != "" {
+		return r.evaluateSize(dir)
+	}
+
+	return r.evaluateGlob(dir)
+}
+
+func (r *Count) evaluateGlob(dir string) (bool, error) {
+	log.Debug("   [count] %s\n", r.Glob)
+
+	re, err := utils.CompileGlob(r.Glob)
+	if err != nil {
+		return false, err
+	}
+
+	w := walker{MaxDepth: r.MaxDepth, Include: []string{r.Glob}}
+	if w.MaxDepth <= 0 {
+		if strings.Contains(r.Glob, "**") {
+			w.MaxDepth = defaultCountMaxDepth
+		} else {
+			w.MaxDepth = strings.Count(r.Glob, "/") + 1
+		}
+	}
+
+	count, done := int64(0), false
+
+	err = w.walk(dir, func(rel string, d fs.DirEntry) error {
+		if re.MatchString(rel) {
+			count++
+			done = r.exceeds(count)
+
+			if done {
+				return errStopWalk
+			}
+		}
+
+		return nil
+	})
+
+	log.Debug("   [count] %d entries\n", count)
+
+	return r.inRange(count), err
+}
+
+func (r *Count) evaluateSize(dir string) (bool, error) {
+	log.Debug("   [count] size of %s\n", r.SizeOf)
+
+	root := filepath.Join(dir, filepath.FromSlash(r.SizeOf))
+
+	info, err := os.Lstat(root)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	total := info.Size()
+
+	if info.IsDir() {
+		total = 0
+		w := walker{MaxDepth: r.MaxDepth, All: true}
+
+		err = w.walk(root, func(rel string, d fs.DirEntry) error {
+			if !d.Type().IsRegular() {
+				return nil
+			}
+
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+
+			if r.exceeds(total) {
+				return errStopWalk
+			}
+
+			return nil
+		})
+	}
+
+	log.Debug("   [count] %d bytes\n", total)
+
+	return r.inRange(total), err
+}
+
+// exceeds reports whether a running total is large enough that counting
+// further cannot change the result.
+func (r *Count) exceeds(n int64) bool {
+	if r.Max > 0 {
+		return n > r.Max
+	}
+
+	return n >= r.Min
+}
+
+func (r *Count) inRange(n int64) bool {
+	return n >= r.Min && (r.Max <= 0 || n <= r.Max)
+}
+
+// tags add migrations count glob=migrations/*.sql min=50
+// tags add large count size_of=. min=1G
+func (r *Count) Add(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("No settings specified.")
+	}
+
+	cfg := r.GetConfig()
+
+	for _, arg := range args {
+		key, val, ok := strings.Cut(arg, "=")
+		if !ok {
+			return fmt.Errorf("Invalid setting \"%s\", expected <KEY>=<VALUE>.", arg)
+		}
+
+		switch key {
+		case "glob":
+			delete(cfg, "size_of")
+			cfg[key] = val
+		case "size_of":
+			delete(cfg, "glob")
+			cfg[key] = val
+		case "min", "max", "max_depth":
+			if num, err := strconv.ParseFloat(val, 64); err == nil {
+				cfg[key] = num
+			} else {
+				cfg[key] = val
+			}
+		default:
+			return fmt.Errorf("Unknown setting \"%s\".", key)
+		}
+	}
+
+	for key, val := range cfg {
+		if num, ok := val.(int64); ok {
+			cfg[key] = float64(num)
+		} else if num, ok := val.(int); ok {
+			cfg[key] = float64(num)
+		}
+	}
+
+	*r = Count{}
+
+	return r.Load(cfg)
+}
+
+func (r *Count) Del(args []string) error {
+	for _, key := range args {
+		switch key {
+		case "min":
+			r.Min = 0
+		case "max":
+			r.Max = 0
+		case "max_depth":
+			r.MaxDepth = 0
+		default:
+			return fmt.Errorf("Cannot remove setting \"%s\".", key)
+		}
+	}
+
+	if len(args) == 0 {
+		*r = Count{}
+	}
+
+	return nil
+}
+
+func (r *Count) String() string {
+	s := "[count] "
+
+	if r.SizeOf != "" {
+		s += fmt.Sprintf("size of %s", r.SizeOf)
+	} else {
+		s += fmt.Sprintf("entries matching %s", r.Glob)
+	}
+
+	format := func(n int64) string {
+		if r.SizeOf != "" {
+			return utils.FormatSize(n)
+		}
+		return strconv.FormatInt(n, 10)
+	}
+
+	if r.Min > 0 {
+		s += " >= " + format(r.Min)
+	}
+	if r.Max > 0 {
+		s += " <= " + format(r.Max)
+	}
+
+	return s
+}
@@ -0,0 +1,108 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates files with the given contents under a directory.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, contents := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCountEvaluate(t *testing.T) {
+	dir := t.TempDir()
+
+	writeFiles(t, dir, map[string]string{
+		"migrations/001.sql":     "",
+		"migrations/002.sql":     "",
+		"migrations/003.sql":     "",
+		"migrations/README.md":   "",
+		"src/main.go":            strings.Repeat("x", 1000),
+		"src/pkg/pkg.go":         strings.Repeat("x", 2000),
+		"src/pkg/inner/inner.go": strings.Repeat("x", 3000),
+	})
+
+	tests := []struct {
+		name string
+		rule Count
+		want bool
+	}{
+		{"min", Count{Glob: "migrations/*.sql", Min: 3}, true},
+		{"below min", Count{Glob: "migrations/*.sql", Min: 4}, false},
+		{"max", Count{Glob: "migrations/*.sql", Max: 3}, true},
+		{"above max", Count{Glob: "migrations/*.sql", Max: 2}, false},
+		{"range", Count{Glob: "migrations/*", Min: 4, Max: 4}, true},
+		{"no matches", Count{Glob: "*.py", Min: 1}, false},
+		{"no matches and no min", Count{Glob: "*.py", Max: 1}, true},
+		{"recursive glob", Count{Glob: "**/*.go", Min: 3}, true},
+		{"recursive glob with max depth", Count{Glob: "**/*.go", Min: 3, MaxDepth: 3}, false},
+		{"single segment glob", Count{Glob: "*.go", Min: 1}, false},
+		{"size of file", Count{SizeOf: "src/main.go", Min: 1000, Max: 1000}, true},
+		{"size of dir", Count{SizeOf: "src", Min: 6000}, true},
+		{"size of dir above max", Count{SizeOf: "src", Max: 5999}, false},
+		{"size of dir with max depth", Count{SizeOf: "src", Max: 3000, MaxDepth: 2}, true},
+		{"size of missing", Count{SizeOf: "missing"}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.rule.Evaluate(dir)
+			if err != nil {
+				t.Fatalf("Evaluate() error: %s", err)
+			}
+
+			if got != test.want {
+				t.Errorf("Evaluate() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCountLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     map[string]interface{}
+		want    Count
+		wantErr bool
+	}{
+		{"glob", map[string]interface{}{"glob": "*.sql", "min": 50.0}, Count{Glob: "*.sql", Min: 50}, false},
+		{"size", map[string]interface{}{"size_of": ".", "max": "1K"}, Count{SizeOf: ".", Max: 1024}, false},
+		{"neither", map[string]interface{}{"min": 1.0}, Count{}, true},
+		{"both", map[string]interface{}{"glob": "*", "size_of": "."}, Count{}, true},
+		{"invalid size", map[string]interface{}{"size_of": ".", "min": "lots"}, Count{}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Count{}
+			err := got.Load(test.cfg)
+
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("Load() = %+v, want an error", got)
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("Load() error: %s", err)
+			}
+
+			if got != test.want {
+				t.Errorf("Load() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package rules
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/mecha/tags/log"
+)
+
+type (
+	Env struct {
+		Set     []string
+		Equals  map[string]string
+		Matches map[string]string
+
+		// LookupEnv is used to read environment variables. If nil, the
+		// process' environment is used.
+		LookupEnv func(key string) (string, bool)
+
+		// compiled holds the compiled patterns of Matches, so that they are
+		// not compiled again every time the rule is evaluated.
+		compiled map[string]*regexp.Regexp
+	}
+)
+
+func init() {
+	register("env", "Matches on environment variables.", func() Rule { return &Env{} },
+		Field{Name: "set", Type: ListField, Description: "Variables that must be set."},
+		Field{Name: "equals", Type: MapField, Description: "Maps variables to the values they must equal."},
+		Field{Name: "matches", Type: MapField, Description: "Maps variables to regular expressions their values must match."},
+	)
+}
+
+func (r *Env) Load(cfg map[string]interface{}) error {
+	var err error
+
+	if r.Set, err = loadList("env", "set", cfg); err != nil {
+		return err
+	}
+	if r.Equals, err = loadMap("env", "equals", cfg); err != nil {
+		return err
+	}
+	if r.Matches, err = loadMap("env", "matches", cfg); err != nil {
+		return err
+	}
+
+	if r.Set == nil && r.Equals == nil && r.Matches == nil {
+		return fmt.Errorf("[env] config needs at least one of \"set\", \"equals\" or \"matches\"\n")
+	}
+
+	r.compiled = make(map[string]*regexp.Regexp, len(r.Matches))
+
+	for name, pattern := range r.Matches {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("[env] invalid pattern for \"%s\": %s", name, err)
+		}
+
+		r.compiled[pattern] = re
+	}
+
+	return nil
+}
+
+func (r *Env) GetConfig() map[string]interface{} {
+	cfg := map[string]interface{}{}
+
+	if len(r.Set) > 0 {
+		cfg["set"] = r.Set
+	}
+	if len(r.Equals) > 0 {
+		cfg["equals"] = r.Equals
+	}
+	if len(r.Matches) > 0 {
+		cfg["matches"] = r.Matches
+	}
+
+	return cfg
+}
+
+func (r *Env) lookup(name string) (string, bool) {
+	if r.LookupEnv != nil {
+		return r.LookupEnv(name)
+	}
+
+	return os.LookupEnv(name)
+}
+
+// pattern returns a compiled pattern, compiling it if the rule was not
+// loaded from a config.
+func (r *Env) pattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := r.compiled[pattern]; ok {
+		return re, nil
+	}
+
+	return regexp.Compile(pattern)
+}
+
+func (r *Env) Evaluate(dir string) (bool, error) {
+	for _, name := range r.Set {
+		log.Debug("   [env] %s\n", name)
+
+		if _, ok := r.lookup(name); ok {
+			return true, nil
+		}
+	}
+
+	for name, value := range r.Equals {
+		log.Debug("   [env] %s=%s\n", name, value)
+
+		if actual, ok := r.lookup(name); ok && actual == value {
+			return true, nil
+		}
+	}
+
+	for name, pattern := range r.Matches {
+		log.Debug("   [env] %s~%s\n", name, pattern)
+
+		actual, ok := r.lookup(name)
+		if !ok {
+			continue
+		}
+
+		re, err := r.pattern(pattern)
+		if err != nil {
+			return false, err
+		}
+
+		if re.MatchString(actual) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// parseEnvArg splits a command line argument into a variable name, an
+// operator ("=" or "~") and a value. Plain names have an empty operator.
+func parseEnvArg(arg string) (string, string, string) {
+	idx := strings.IndexAny(arg, "=~")
+	if idx < 0 {
+		return arg, "", ""
+	}
+
+	return arg[:idx], arg[idx : idx+1], arg[idx+1:]
+}
+
+// tags add nix env IN_NIX_SHELL
+// tags add xterm env TERM~^xterm
+func (r *Env) Add(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("No variables specified.")
+	}
+
+	for _, arg := range args {
+		name, op, value := parseEnvArg(arg)
+
+		if name == "" {
+			return fmt.Errorf("Invalid variable: \"%s\"", arg)
+		}
+
+		switch op {
+		case "":
+			r.Set = append(r.Set, name)
+		case "=":
+			if r.Equals == nil {
+				r.Equals = make(map[string]string)
+			}
+			r.Equals[name] = value
+		case "~":
+			re, err := regexp.Compile(value)
+			if err != nil {
+				return fmt.Errorf("Invalid pattern for \"%s\": %s", name, err)
+			}
+			if r.Matches == nil {
+				r.Matches = make(map[string]string)
+			}
+			if r.compiled == nil {
+				r.compiled = make(map[string]*regexp.Regexp)
+			}
+			r.Matches[name] = value
+			r.compiled[value] = re
+		}
+	}
+
+	return nil
+}
+
+func (r *Env) Del(args []string) error {
+	if len(args) == 0 {
+		r.Set = nil
+		r.Equals = nil
+		r.Matches = nil
+		return nil
+	}
+
+	for _, arg := range args {
+		name, op, value := parseEnvArg(arg)
+
+		switch op {
+		case "":
+			r.Set = removeAll(r.Set, []string{name})
+		case "=":
+			if r.Equals[name] == value {
+				delete(r.Equals, name)
+			}
+		case "~":
+			if r.Matches[name] == value {
+				delete(r.Matches, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *Env) String() string {
+	s := ""
+	for _, name := range r.Set {
+		s += fmt.Sprintf("\n[env] %s", name)
+	}
+	for _, name := range sortedKeys(r.Equals) {
+		s += fmt.Sprintf("\n[env] %s=%s", name, r.Equals[name])
+	}
+	for _, name := range sortedKeys(r.Matches) {
+		s += fmt.Sprintf("\n[env] %s~%s", name, r.Matches[name])
+	}
+
+	return strings.TrimLeft(s, "\n")
+}
@@ -0,0 +1,52 @@
+package rules
+
+import (
+	"testing"
+)
+
+func TestEnvEvaluate(t *testing.T) {
+	env := map[string]string{
+		"EMPTY": "",
+		"TERM":  "xterm-256color",
+	}
+
+	lookupEnv := func(key string) (string, bool) {
+		val, ok := env[key]
+		return val, ok
+	}
+
+	tests := []struct {
+		name string
+		rule Env
+		want bool
+	}{
+		{"set", Env{Set: []string{"TERM"}}, true},
+		{"set and empty", Env{Set: []string{"EMPTY"}}, true},
+		{"set and unset", Env{Set: []string{"UNSET"}}, false},
+		{"equals", Env{Equals: map[string]string{"TERM": "xterm-256color"}}, true},
+		{"equals other value", Env{Equals: map[string]string{"TERM": "xterm"}}, false},
+		{"equals empty and empty", Env{Equals: map[string]string{"EMPTY": ""}}, true},
+		{"equals empty and unset", Env{Equals: map[string]string{"UNSET": ""}}, false},
+		{"matches", Env{Matches: map[string]string{"TERM": "^xterm"}}, true},
+		{"matches other value", Env{Matches: map[string]string{"TERM": "^screen"}}, false},
+		{"matches empty and empty", Env{Matches: map[string]string{"EMPTY": "^$"}}, true},
+		{"matches empty and unset", Env{Matches: map[string]string{"UNSET": "^$"}}, false},
+		{"any of", Env{Set: []string{"UNSET"}, Equals: map[string]string{"TERM": "xterm-256color"}}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule := test.rule
+			rule.LookupEnv = lookupEnv
+
+			got, err := rule.Evaluate(t.TempDir())
+			if err != nil {
+				t.Fatalf("Evaluate() error: %s", err)
+			}
+
+			if got != test.want {
+				t.Errorf("Evaluate() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
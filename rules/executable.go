@@ -0,0 +1,369 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mecha/tags/log"
+)
+
+type (
+	Executable struct {
+		Commands []string
+		Versions map[string]VersionProbe
+	}
+
+	// VersionProbe describes how to find the version of an executable: it is
+	// run with Flag, and the first match of Pattern in its output is compared
+	// against Min.
+	VersionProbe struct {
+		Flag    string
+		Pattern string
+		Min     string
+
+		// re is the compiled Pattern, or the default pattern, so that it is
+		// not compiled again every time the probe is checked.
+		re *regexp.Regexp
+	}
+
+	// execEntry is a cached path lookup or version probe. The once ensures
+	// that each is only run once, without holding the cache's lock while it
+	// runs.
+	execEntry struct {
+		once   sync.Once
+		output string
+		err    error
+	}
+)
+
+func init() {
+	register("executable", "Matches if commands are installed on the $PATH.", func() Rule { return &Executable{} },
+		Field{Name: "commands", Type: ListField, Description: "The commands that must be installed.", Required: true},
+		Field{Name: "versions", Type: MapField, Description: "Maps commands to the minimum versions they must have.", Fields: []Field{
+			{Name: "flag", Type: StringField, Description: "The flag that prints the version. Defaults to \"--version\"."},
+			{Name: "pattern", Type: StringField, Description: "A regular expression that finds the version in the output."},
+			{Name: "min", Type: StringField, Description: "The minimum version."},
+		}},
+	)
+}
+
+const (
+	defaultVersionFlag    = "--version"
+	defaultVersionPattern = `\d+(?:\.\d+)+`
+
+	// versionProbeTimeout is how long a version probe may run before it is
+	// killed, so that a command that hangs does not hang tags too.
+	versionProbeTimeout = 2 * time.Second
+)
+
+// The results of path lookups and version probes are cached for the duration
+// of the process, since the same executables are typically checked by many
+// tags and the probes involve spawning processes.
+var (
+	execCache   = make(map[string]*execEntry)
+	execCacheMu sync.Mutex
+)
+
+func cachedExec(key string, fn func() (string, error)) (string, error) {
+	execCacheMu.Lock()
+	entry, ok := execCache[key]
+	if !ok {
+		entry = &execEntry{}
+		execCache[key] = entry
+	}
+	execCacheMu.Unlock()
+
+	entry.once.Do(func() {
+		entry.output, entry.err = fn()
+	})
+
+	return entry.output, entry.err
+}
+
+func lookPath(name string) (string, error) {
+	return cachedExec("path\x00"+name, func() (string, error) {
+		return exec.LookPath(name)
+	})
+}
+
+func probeVersion(path string, flag string) (string, error) {
+	return cachedExec("version\x00"+path+"\x00"+flag, func() (string, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), versionProbeTimeout)
+		defer cancel()
+
+		// A nil Stdin reads from the null device, so commands that wait for
+		// input get EOF right away. WaitDelay stops waiting for the output of
+		// any children that outlive a killed command.
+		cmd := exec.CommandContext(ctx, path, flag)
+		cmd.Stdin = nil
+		cmd.WaitDelay = 100 * time.Millisecond
+
+		out, err := cmd.CombinedOutput()
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("%s %s timed out after %s", path, flag, versionProbeTimeout)
+		}
+
+		// Some tools exit with a non-zero status when printing their version,
+		// so only failures to run the command are treated as errors.
+		if _, ok := err.(*exec.ExitError); ok {
+			err = nil
+		}
+
+		return string(out), err
+	})
+}
+
+func (r *Executable) Load(cfg map[string]interface{}) error {
+	var err error
+
+	r.Commands, err = loadList("executable", "commands", cfg)
+	if err != nil {
+		return err
+	} else if r.Commands == nil {
+		return fmt.Errorf("[executable] no \"commands\" key in config\n")
+	}
+
+	versionsVal, ok := cfg["versions"]
+	if !ok {
+		return nil
+	}
+
+	dict, ok := versionsVal.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("[executable] \"versions\" is not an object: %v", versionsVal)
+	}
+
+	r.Versions = make(map[string]VersionProbe, len(dict))
+
+	for name, val := range dict {
+		probeCfg, ok := val.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("[executable] invalid version probe for \"%s\": %v", name, val)
+		}
+
+		probe := VersionProbe{}
+		fields := map[string]*string{
+			"flag":    &probe.Flag,
+			"pattern": &probe.Pattern,
+			"min":     &probe.Min,
+		}
+
+		for key, ptr := range fields {
+			if v, ok := probeCfg[key]; ok {
+				if *ptr, ok = v.(string); !ok {
+					return fmt.Errorf("[executable] invalid \"%s\" for \"%s\": %v", key, name, v)
+				}
+			}
+		}
+
+		if probe.re, err = probe.compile(); err != nil {
+			return fmt.Errorf("[executable] invalid pattern for \"%s\": %s", name, err)
+		}
+
+		r.Versions[name] = probe
+	}
+
+	return nil
+}
+
+func (r *Executable) GetConfig() map[string]interface{} {
+	cfg := map[string]interface{}{
+		"commands": r.Commands,
+	}
+
+	if len(r.Versions) > 0 {
+		versions := make(map[string]interface{}, len(r.Versions))
+
+		for name, probe := range r.Versions {
+			probeCfg := map[string]interface{}{}
+
+			if probe.Flag != "" {
+				probeCfg["flag"] = probe.Flag
+			}
+			if probe.Pattern != "" {
+				probeCfg["pattern"] = probe.Pattern
+			}
+			if probe.Min != "" {
+				probeCfg["min"] = probe.Min
+			}
+
+			versions[name] = probeCfg
+		}
+
+		cfg["versions"] = versions
+	}
+
+	return cfg
+}
+
+func (r *Executable) Evaluate(dir string) (bool, error) {
+	for _, name := range r.Commands {
+		log.Debug("   [executable] %s\n", name)
+
+		path, err := lookPath(name)
+		if err != nil {
+			continue
+		}
+
+		probe, ok := r.Versions[name]
+		if !ok || probe.Min == "" {
+			return true, nil
+		}
+
+		ok, err = probe.check(path)
+		if err != nil {
+			log.Debug("   [executable] %s: %s\n", name, err)
+		} else if ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// compile compiles the probe's pattern, or the default pattern if it has
+// none.
+func (probe VersionProbe) compile() (*regexp.Regexp, error) {
+	if probe.Pattern == "" {
+		return regexp.Compile(defaultVersionPattern)
+	}
+
+	return regexp.Compile(probe.Pattern)
+}
+
+func (probe VersionProbe) check(path string) (bool, error) {
+	flag := probe.Flag
+	if flag == "" {
+		flag = defaultVersionFlag
+	}
+
+	re := probe.re
+	if re == nil {
+		var err error
+		if re, err = probe.compile(); err != nil {
+			return false, err
+		}
+	}
+
+	output, err := probeVersion(path, flag)
+	if err != nil {
+		return false, err
+	}
+
+	match := re.FindStringSubmatch(output)
+	if match == nil {
+		return false, fmt.Errorf("no version found in output")
+	}
+
+	// Use the first capture group if there is one, else the whole match.
+	version := match[0]
+	if re.NumSubexp() > 0 && match[1] != "" {
+		version = match[1]
+	}
+
+	log.Debug("   [executable] version %s >= %s\n", version, probe.Min)
+
+	return compareVersions(version, probe.Min) >= 0, nil
+}
+
+// compareVersions compares two dot-separated version strings numerically,
+// returning -1, 0 or 1. Non-numeric suffixes of a component are ignored.
+func compareVersions(a, b string) int {
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		aNum, bNum := 0, 0
+
+		if i < len(aParts) {
+			aNum = leadingInt(aParts[i])
+		}
+		if i < len(bParts) {
+			bNum = leadingInt(bParts[i])
+		}
+
+		if aNum < bNum {
+			return -1
+		} else if aNum > bNum {
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func leadingInt(s string) int {
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+
+	n, _ := strconv.Atoi(s[:end])
+
+	return n
+}
+
+// tags add docker executable docker
+// tags add compose executable docker>=20.10
+func (r *Executable) Add(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("No commands specified.")
+	}
+
+	for _, arg := range args {
+		name, min, hasMin := strings.Cut(arg, ">=")
+
+		if name == "" {
+			return fmt.Errorf("Invalid command: \"%s\"", arg)
+		}
+
+		r.Commands = append(removeAll(r.Commands, []string{name}), name)
+
+		if hasMin {
+			if r.Versions == nil {
+				r.Versions = make(map[string]VersionProbe)
+			}
+
+			probe := r.Versions[name]
+			probe.Min = min
+			r.Versions[name] = probe
+		}
+	}
+
+	return nil
+}
+
+func (r *Executable) Del(args []string) error {
+	if len(args) == 0 {
+		r.Commands = make([]string, 0)
+		r.Versions = nil
+		return nil
+	}
+
+	for _, arg := range args {
+		name, _, _ := strings.Cut(arg, ">=")
+		r.Commands = removeAll(r.Commands, []string{name})
+		delete(r.Versions, name)
+	}
+
+	return nil
+}
+
+func (r *Executable) String() string {
+	s := ""
+	for _, name := range r.Commands {
+		if probe, ok := r.Versions[name]; ok && probe.Min != "" {
+			s += fmt.Sprintf("\n[executable] %s>=%s", name, probe.Min)
+		} else {
+			s += fmt.Sprintf("\n[executable] %s", name)
+		}
+	}
+
+	return strings.TrimLeft(s, "\n")
+}
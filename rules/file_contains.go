@@ -14,6 +14,12 @@ type (
 	}
 )
 
+func init() {
+	register("file_contains", "Matches if any of the files contain a piece of text.", func() Rule { return &FileContains{} },
+		Field{Name: "search", Type: MapField, Description: "Maps files, relative to the directory, to the text to look for in them.", Required: true, Expand: true},
+	)
+}
+
 func (r *FileContains) Load(cfg map[string]interface{}) error {
 	if r.Search == nil {
 		r.Search = make(map[string]string)
@@ -29,11 +35,11 @@ func (r *FileContains) Load(cfg map[string]interface{}) error {
 		return fmt.Errorf("[file_contains] \"search\" is not an object: %v", searchVal)
 	}
 
-	for file, text := range dict {
-		text, ok := text.(string)
+	for file, val := range dict {
+		text, ok := val.(string)
 
 		if !ok {
-			return fmt.Errorf("[file_contains] invalid value for file \"%s\": %v\n", file, text)
+			return fmt.Errorf("[file_contains] invalid value for file \"%s\": %v\n", file, val)
 		}
 
 		r.Search[file] = text
@@ -75,10 +81,14 @@ func (r *FileContains) Add(args []string) error {
 		return fmt.Errorf("No mappings provided")
 	} else if len(args)%2 != 0 {
 		return fmt.Errorf("Odd number of arguments")
-	} else {
-		for i := 0; i < len(args); i += 2 {
-			r.Search[args[i]] = args[i+1]
-		}
+	}
+
+	if r.Search == nil {
+		r.Search = make(map[string]string)
+	}
+
+	for i := 0; i < len(args); i += 2 {
+		r.Search[args[i]] = args[i+1]
 	}
 
 	return nil
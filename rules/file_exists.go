@@ -14,6 +14,12 @@ type (
 	}
 )
 
+func init() {
+	register("file_exists", "Matches if any of the files exist in the directory.", func() Rule { return &FileExists{} },
+		Field{Name: "files", Type: ListField, Description: "The files to look for, relative to the directory.", Required: true, Expand: true},
+	)
+}
+
 func (rule *FileExists) Load(cfg map[string]interface{}) error {
 	if rule.Files == nil {
 		rule.Files = make([]string, 0)
@@ -29,11 +35,11 @@ func (rule *FileExists) Load(cfg map[string]interface{}) error {
 		return fmt.Errorf("[file_exists] \"files\" is not a list: %v", filesVal)
 	}
 
-	for _, file := range list {
-		file, ok := file.(string)
+	for _, item := range list {
+		file, ok := item.(string)
 
 		if !ok {
-			return fmt.Errorf("[file_exists] invalid value: %v\n", file)
+			return fmt.Errorf("[file_exists] invalid value: %v\n", item)
 		}
 
 		rule.Files = append(rule.Files, file)
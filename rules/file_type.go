@@ -0,0 +1,256 @@
+package rules
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mecha/tags/log"
+	"github.com/mecha/tags/utils"
+)
+
+type (
+	// FileType matches directories that have a file of a specific type. The
+	// type is identified by the file's leading bytes, rather than by its
+	// extension. Keys of Types are files or globs, and values are the names
+	// of types in the signature table, or "script:<interpreter>".
+	FileType struct {
+		Types map[string]string
+	}
+
+	fileSignature struct {
+		offset int
+		magic  string
+	}
+)
+
+func init() {
+	register("file_type", "Matches on the types of files, based on their contents.", func() Rule { return &FileType{} },
+		Field{Name: "types", Type: MapField, Description: "Maps files or globs to file types.", Required: true, Expand: true},
+	)
+}
+
+// fileSignatures maps type names to the magic bytes that identify them.
+var fileSignatures = map[string][]fileSignature{
+	"elf":    {{0, "\x7fELF"}},
+	"macho":  {{0, "\xfe\xed\xfa\xce"}, {0, "\xfe\xed\xfa\xcf"}, {0, "\xce\xfa\xed\xfe"}, {0, "\xcf\xfa\xed\xfe"}},
+	"pe":     {{0, "MZ"}},
+	"wasm":   {{0, "\x00asm"}},
+	"png":    {{0, "\x89PNG\r\n\x1a\n"}},
+	"jpeg":   {{0, "\xff\xd8\xff"}},
+	"gif":    {{0, "GIF87a"}, {0, "GIF89a"}},
+	"webp":   {{8, "WEBP"}},
+	"pdf":    {{0, "%PDF-"}},
+	"sqlite": {{0, "SQLite format 3\x00"}},
+	"zip":    {{0, "PK\x03\x04"}, {0, "PK\x05\x06"}},
+	"gzip":   {{0, "\x1f\x8b"}},
+	"bzip2":  {{0, "BZh"}},
+	"xz":     {{0, "\xfd7zXZ\x00"}},
+	"zstd":   {{0, "\x28\xb5\x2f\xfd"}},
+	"7z":     {{0, "7z\xbc\xaf\x27\x1c"}},
+	"tar":    {{257, "ustar"}},
+}
+
+const (
+	// The number of bytes to read from files, which is enough for the
+	// longest signature and for typical shebang lines.
+	fileTypePrefixLen = 512
+
+	scriptType = "script"
+
+	defaultFileTypeMaxDepth = 5
+	defaultFileTypeMaxFiles = 2000
+)
+
+// isFileType reports whether a type name is known.
+func isFileType(name string) bool {
+	_, ok := fileSignatures[name]
+
+	return ok || name == scriptType || strings.HasPrefix(name, scriptType+":")
+}
+
+// FileTypes returns the names of the types in the signature table.
+func FileTypes() []string {
+	names := make([]string, 0, len(fileSignatures)+1)
+	for name := range fileSignatures {
+		names = append(names, name)
+	}
+
+	names = append(names, scriptType)
+	sort.Strings(names)
+
+	return names
+}
+
+func (r *FileType) Load(cfg map[string]interface{}) error {
+	var err error
+
+	r.Types, err = loadMap("file_type", "types", cfg)
+	if err != nil {
+		return err
+	} else if r.Types == nil {
+		return fmt.Errorf("[file_type] no \"types\" key in config\n")
+	}
+
+	for file, fType := range r.Types {
+		if !isFileType(fType) {
+			return fmt.Errorf("[file_type] unknown type for file \"%s\": %s", file, fType)
+		}
+	}
+
+	return nil
+}
+
+func (r *FileType) GetConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"types": r.Types,
+	}
+}
+
+func (r *FileType) Evaluate(dir string) (bool, error) {
+	for _, file := range sortedKeys(r.Types) {
+		fType := r.Types[file]
+		log.Debug("   [file_type] %s: %s\n", file, fType)
+
+		var (
+			match bool
+			err   error
+		)
+
+		if utils.HasGlobMeta(file) {
+			match, err = anyFileOfType(dir, file, fType)
+		} else {
+			match, err = isOfType(filepath.Join(dir, file), fType)
+			if os.IsNotExist(err) {
+				match, err = false, nil
+			}
+		}
+
+		if err != nil || match {
+			return match, err
+		}
+	}
+
+	return false, nil
+}
+
+func anyFileOfType(dir string, glob string, fType string) (bool, error) {
+	re, err := utils.CompileGlob(glob)
+	if err != nil {
+		return false, err
+	}
+
+	w := walker{MaxFiles: defaultFileTypeMaxFiles, Include: []string{glob}}
+	if strings.Contains(glob, "**") {
+		w.MaxDepth = defaultFileTypeMaxDepth
+	} else {
+		w.MaxDepth = strings.Count(glob, "/") + 1
+	}
+
+	found := false
+
+	err = w.walk(dir, func(rel string, d fs.DirEntry) error {
+		if !d.Type().IsRegular() || !re.MatchString(rel) {
+			return nil
+		}
+
+		match, err := isOfType(filepath.Join(dir, filepath.FromSlash(rel)), fType)
+		if err != nil {
+			log.Debug("   [file_type] %s: %s\n", rel, err)
+		} else if match {
+			found = true
+			return errStopWalk
+		}
+
+		return nil
+	})
+
+	return found, err
+}
+
+// isOfType reads the start of a file and checks whether it has the given
+// type's signature.
+func isOfType(file string, fType string) (bool, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	prefix := make([]byte, fileTypePrefixLen)
+	n, err := io.ReadFull(f, prefix)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+
+	prefix = prefix[:n]
+
+	if fType == scriptType {
+		return bytes.HasPrefix(prefix, []byte("#!")), nil
+	}
+
+	if interp, ok := strings.CutPrefix(fType, scriptType+":"); ok {
+		return parseShebang(prefix) == strings.TrimRight(interp, "0123456789."), nil
+	}
+
+	for _, sig := range fileSignatures[fType] {
+		end := sig.offset + len(sig.magic)
+
+		if end <= len(prefix) && string(prefix[sig.offset:end]) == sig.magic {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// tags add binaries file_type "bin/*" elf
+// tags add py-scripts file_type "*" script:python
+func (r *FileType) Add(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("No mappings provided")
+	} else if len(args)%2 != 0 {
+		return fmt.Errorf("Odd number of arguments")
+	}
+
+	if r.Types == nil {
+		r.Types = make(map[string]string)
+	}
+
+	for i := 0; i < len(args); i += 2 {
+		if !isFileType(args[i+1]) {
+			return fmt.Errorf("Unknown file type \"%s\". Known types: %s", args[i+1], strings.Join(FileTypes(), ", "))
+		}
+
+		r.Types[args[i]] = args[i+1]
+	}
+
+	return nil
+}
+
+func (r *FileType) Del(args []string) error {
+	if len(args) == 0 {
+		r.Types = make(map[string]string)
+		return nil
+	}
+
+	for _, file := range args {
+		delete(r.Types, file)
+	}
+
+	return nil
+}
+
+func (r *FileType) String() string {
+	s := ""
+	for _, file := range sortedKeys(r.Types) {
+		s += fmt.Sprintf("\n[file_type] %s is %s", file, r.Types[file])
+	}
+
+	return strings.TrimLeft(s, "\n")
+}
@@ -0,0 +1,84 @@
+package rules
+
+import (
+	"testing"
+)
+
+func TestFileTypeEvaluate(t *testing.T) {
+	dir := t.TempDir()
+
+	writeFiles(t, dir, map[string]string{
+		"bin/tool":      "\x7fELF\x02\x01\x01",
+		"bin/tool.exe":  "MZ\x90\x00",
+		"logo.png":      "\x89PNG\r\n\x1a\n....",
+		"fake.png":      "not a png",
+		"image.webp":    "RIFF\x00\x00\x00\x00WEBPVP8 ",
+		"scripts/run":   "#!/usr/bin/env python3\nprint()\n",
+		"scripts/build": "#!/bin/bash\n",
+		"archive.tar":   string(make([]byte, 257)) + "ustar\x00",
+		"short":         "\x7f",
+		"deep/a/b/db":   "SQLite format 3\x00",
+	})
+
+	tests := []struct {
+		name  string
+		types map[string]string
+		want  bool
+	}{
+		{"file", map[string]string{"bin/tool": "elf"}, true},
+		{"file of other type", map[string]string{"bin/tool": "pe"}, false},
+		{"missing file", map[string]string{"bin/missing": "elf"}, false},
+		{"short file", map[string]string{"short": "elf"}, false},
+		{"signature at offset", map[string]string{"image.webp": "webp"}, true},
+		{"signature at large offset", map[string]string{"archive.tar": "tar"}, true},
+		{"extension does not matter", map[string]string{"fake.png": "png"}, false},
+		{"glob", map[string]string{"bin/*": "pe"}, true},
+		{"glob without match", map[string]string{"*": "elf"}, false},
+		{"any of", map[string]string{"fake.png": "png", "logo.png": "png"}, true},
+		{"script", map[string]string{"scripts/build": "script"}, true},
+		{"script interpreter", map[string]string{"scripts/*": "script:python"}, true},
+		{"script interpreter with version", map[string]string{"scripts/run": "script:python3"}, true},
+		{"script of other interpreter", map[string]string{"scripts/run": "script:bash"}, false},
+		{"not a script", map[string]string{"bin/tool": "script"}, false},
+		{"recursive glob", map[string]string{"**/db": "sqlite"}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule := FileType{Types: test.types}
+
+			got, err := rule.Evaluate(dir)
+			if err != nil {
+				t.Fatalf("Evaluate() error: %s", err)
+			}
+
+			if got != test.want {
+				t.Errorf("Evaluate() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFileTypeLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		types   map[string]interface{}
+		wantErr bool
+	}{
+		{"signature", map[string]interface{}{"bin/*": "elf"}, false},
+		{"script", map[string]interface{}{"*": "script"}, false},
+		{"script interpreter", map[string]interface{}{"*": "script:node"}, false},
+		{"unknown type", map[string]interface{}{"*": "exe"}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule := FileType{}
+			err := rule.Load(map[string]interface{}{"types": test.types})
+
+			if (err != nil) != test.wantErr {
+				t.Errorf("Load() error = %v, want error %v", err, test.wantErr)
+			}
+		})
+	}
+}
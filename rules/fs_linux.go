@@ -0,0 +1,53 @@
+//go:build linux
+
+package rules
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// fsMagics maps the magic numbers returned by statfs(2) to filesystem names.
+// The numbers are 32 bits wide, but Statfs_t.Type is a signed integer whose
+// size depends on the architecture, so it is converted to a uint32 first.
+var fsMagics = map[uint32]string{
+	0x9123683e: "btrfs",
+	0x00c36400: "ceph",
+	0xff534d42: "cifs",
+	0x28cd3d45: "cramfs",
+	0xf15f:     "ecryptfs",
+	0xef53:     "ext4",
+	0xf2f52010: "f2fs",
+	0x65735546: "fuse",
+	0x01021997: "9p",
+	0x9660:     "iso9660",
+	0x6969:     "nfs",
+	0x5346544e: "ntfs",
+	0x794c7630: "overlayfs",
+	0x9fa0:     "proc",
+	0x858458f6: "ramfs",
+	0xfe534d42: "smb2",
+	0x73717368: "squashfs",
+	0x62656572: "sysfs",
+	0x01021994: "tmpfs",
+	0x4d44:     "vfat",
+	0x58465342: "xfs",
+	0x2fc12fc1: "zfs",
+}
+
+// fsType returns the name of the filesystem type that a path is on.
+func fsType(path string) (string, error) {
+	var stat syscall.Statfs_t
+
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return "", err
+	}
+
+	magic := uint32(stat.Type)
+
+	if name, ok := fsMagics[magic]; ok {
+		return name, nil
+	}
+
+	return fmt.Sprintf("0x%x", magic), nil
+}
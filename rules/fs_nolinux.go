@@ -0,0 +1,13 @@
+//go:build !linux
+
+package rules
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// fsType is not supported on this platform.
+func fsType(path string) (string, error) {
+	return "", fmt.Errorf("filesystem types are not supported on %s", runtime.GOOS)
+}
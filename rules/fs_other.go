@@ -0,0 +1,31 @@
+//go:build !unix
+
+package rules
+
+import (
+	"os"
+)
+
+const (
+	accessRead    = 0x4
+	accessWrite   = 0x2
+	accessExecute = 0x1
+)
+
+// canAccess approximates access checks using the path's permission bits,
+// since there is no access(2) on this platform.
+func canAccess(path string, mode uint32) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+
+	perm := uint32(info.Mode().Perm() >> 6)
+
+	return perm&mode == mode
+}
+
+// fileOwner is not supported on this platform.
+func fileOwner(info os.FileInfo) (string, string, bool) {
+	return "", "", false
+}
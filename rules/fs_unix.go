@@ -0,0 +1,31 @@
+//go:build unix
+
+package rules
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+)
+
+const (
+	accessRead    = 0x4
+	accessWrite   = 0x2
+	accessExecute = 0x1
+)
+
+// canAccess reports whether the current user has the given access to a path,
+// using the access(2) syscall so that ACLs and read-only mounts are honored.
+func canAccess(path string, mode uint32) bool {
+	return syscall.Access(path, mode) == nil
+}
+
+// fileOwner returns the user and group IDs of a file's owner.
+func fileOwner(info os.FileInfo) (string, string, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", "", false
+	}
+
+	return strconv.FormatUint(uint64(stat.Uid), 10), strconv.FormatUint(uint64(stat.Gid), 10), true
+}
@@ -0,0 +1,93 @@
+package rules
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/mecha/tags/log"
+)
+
+type (
+	Host struct {
+		Hosts []string
+	}
+)
+
+func init() {
+	register("host", "Matches on the machine's hostname.", func() Rule { return &Host{} },
+		Field{Name: "hosts", Type: ListField, Description: "Hostname patterns.", Required: true},
+	)
+}
+
+func (r *Host) Load(cfg map[string]interface{}) error {
+	var err error
+
+	r.Hosts, err = loadList("host", "hosts", cfg)
+	if err != nil {
+		return err
+	} else if r.Hosts == nil {
+		return fmt.Errorf("[host] no \"hosts\" key in config\n")
+	}
+
+	return nil
+}
+
+func (r *Host) GetConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"hosts": r.Hosts,
+	}
+}
+
+func (r *Host) Evaluate(dir string) (bool, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return false, err
+	}
+
+	hostname = strings.ToLower(hostname)
+
+	for _, pattern := range r.Hosts {
+		log.Debug("   [host] %s\n", pattern)
+
+		match, err := path.Match(strings.ToLower(pattern), hostname)
+		if err != nil {
+			return false, err
+		} else if match {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// tags add work host "*.corp.example.com"
+func (r *Host) Add(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("No hosts specified.")
+	}
+
+	r.Hosts = append(r.Hosts, args...)
+
+	return nil
+}
+
+func (r *Host) Del(args []string) error {
+	if len(args) == 0 {
+		r.Hosts = make([]string, 0)
+	} else {
+		r.Hosts = removeAll(r.Hosts, args)
+	}
+
+	return nil
+}
+
+func (r *Host) String() string {
+	s := ""
+	for _, host := range r.Hosts {
+		s += fmt.Sprintf("\n[host] %s", host)
+	}
+
+	return strings.TrimLeft(s, "\n")
+}
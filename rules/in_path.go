@@ -11,10 +11,20 @@ import (
 
 type (
 	InPath struct {
-		Paths []string
+		Paths           []string
+		Exclude         []string
+		ResolveSymlinks bool
 	}
 )
 
+func init() {
+	register("in_path", "Matches if the directory is inside any of the paths.", func() Rule { return &InPath{} },
+		Field{Name: "paths", Type: ListField, Description: "The paths, which may contain globs.", Required: true, Expand: true},
+		Field{Name: "exclude", Type: ListField, Description: "Paths inside the paths that do not match.", Expand: true},
+		Field{Name: "resolve_symlinks", Type: BoolField, Description: "Whether to resolve symlinks before comparing paths."},
+	)
+}
+
 func (r *InPath) Load(cfg map[string]interface{}) error {
 	if r.Paths == nil {
 		r.Paths = make([]string, 0)
@@ -27,43 +37,118 @@ func (r *InPath) Load(cfg map[string]interface{}) error {
 
 	list, ok := pathsVal.([]interface{})
 	if !ok {
-		return fmt.Errorf("[in_path] \"paths\" is not a list: %v", list)
+		return fmt.Errorf("[in_path] \"paths\" is not a list: %v", pathsVal)
 	}
 
-	for _, path := range list {
-		path, ok := path.(string)
+	for _, item := range list {
+		path, ok := item.(string)
 
 		if !ok {
-			return fmt.Errorf("[in_path] invalid value: %v\n", path)
+			return fmt.Errorf("[in_path] invalid value: %v\n", item)
 		}
 
 		r.Paths = append(r.Paths, path)
 	}
 
+	exclude, err := loadList("in_path", "exclude", cfg)
+	if err != nil {
+		return err
+	}
+
+	r.Exclude = exclude
+
+	if val, ok := cfg["resolve_symlinks"]; ok {
+		if r.ResolveSymlinks, ok = val.(bool); !ok {
+			return fmt.Errorf("[in_path] \"resolve_symlinks\" is not a boolean: %v", val)
+		}
+	}
+
 	return nil
 }
 
 func (r *InPath) GetConfig() map[string]interface{} {
-	return map[string]interface{}{
+	cfg := map[string]interface{}{
 		"paths": r.Paths,
 	}
+
+	if len(r.Exclude) > 0 {
+		cfg["exclude"] = r.Exclude
+	}
+	if r.ResolveSymlinks {
+		cfg["resolve_symlinks"] = true
+	}
+
+	return cfg
 }
 
 func (r *InPath) Evaluate(dir string) (bool, error) {
+	dir, err := r.normalize(dir)
+	if err != nil {
+		return false, err
+	}
+
+	for _, path := range r.Exclude {
+		log.Debug("   [in_path] !%s\n", path)
+
+		if match, err := r.isIn(dir, path); err != nil || match {
+			return false, err
+		}
+	}
+
 	for _, path := range r.Paths {
 		log.Debug("   [in_path] %s\n", path)
 
-		ePath, err := utils.ExpandTilde(path)
+		if match, err := r.isIn(dir, path); err != nil || match {
+			return match, err
+		}
+	}
+
+	return false, nil
+}
 
-		if err != nil {
-			return false, err
+// normalize turns a path into a clean, absolute path, resolving symlinks if
+// the rule is configured to do so. Paths that cannot be resolved, such as
+// ones that do not exist, are left unresolved.
+func (r *InPath) normalize(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	if r.ResolveSymlinks {
+		if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+			return resolved, nil
 		}
+	}
 
-		abs, err := filepath.Abs(ePath)
+	return abs, nil
+}
 
+// isIn reports whether dir is the directory at path, or is inside it. The
+// path may contain glob patterns, in which case dir matches if it or any of
+// its parents match the pattern.
+func (r *InPath) isIn(dir string, path string) (bool, error) {
+	if !utils.HasGlobMeta(path) {
+		base, err := r.normalize(path)
 		if err != nil {
 			return false, err
-		} else if strings.HasPrefix(dir, abs) {
+		}
+
+		return utils.IsWithin(dir, base), nil
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+
+	re, err := utils.CompileGlob(filepath.ToSlash(abs))
+	if err != nil {
+		return false, err
+	}
+
+	for _, parent := range utils.Ancestors(dir) {
+		if re.MatchString(filepath.ToSlash(parent)) {
 			return true, nil
 		}
 	}
@@ -71,13 +156,20 @@ func (r *InPath) Evaluate(dir string) (bool, error) {
 	return false, nil
 }
 
+// Paths prefixed with "!" are added as exclusions.
+//
+// tags add www in_path /var/www "!/var/www/old"
 func (r *InPath) Add(args []string) error {
 	if len(args) == 0 {
 		return fmt.Errorf("No paths specified.")
 	}
 
 	for _, arg := range args {
-		r.Paths = append(r.Paths, arg)
+		if strings.HasPrefix(arg, "!") {
+			r.Exclude = append(r.Exclude, arg[1:])
+		} else {
+			r.Paths = append(r.Paths, arg)
+		}
 	}
 
 	return nil
@@ -86,10 +178,16 @@ func (r *InPath) Add(args []string) error {
 func (r *InPath) Del(args []string) error {
 	if len(args) == 0 {
 		r.Paths = make([]string, 0)
+		r.Exclude = nil
 		return nil
 	}
 
 	for _, arg := range args {
+		if strings.HasPrefix(arg, "!") {
+			r.Exclude = removeAll(r.Exclude, []string{arg[1:]})
+			continue
+		}
+
 		for i, path := range r.Paths {
 			if path == arg {
 				last := len(r.Paths) - 1
@@ -107,6 +205,9 @@ func (r *InPath) String() string {
 	for _, path := range r.Paths {
 		s += fmt.Sprintf("\n[in_path] %s", path)
 	}
+	for _, path := range r.Exclude {
+		s += fmt.Sprintf("\n[in_path] !%s", path)
+	}
 
 	return strings.TrimLeft(s, "\n")
 }
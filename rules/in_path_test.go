@@ -0,0 +1,80 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInPathEvaluate(t *testing.T) {
+	root := t.TempDir()
+	real := filepath.Join(root, "real")
+	link := filepath.Join(root, "link")
+
+	for _, dir := range []string{"real/www/site", "real/www-old/site", "real/www/old", "work/src/pkg"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.Symlink(real, link); err != nil {
+		t.Skipf("cannot create symlinks: %s", err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(filepath.Join(root, "work")); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	www := filepath.Join(real, "www")
+
+	tests := []struct {
+		name string
+		rule InPath
+		dir  string
+		want bool
+	}{
+		{"same", InPath{Paths: []string{www}}, www, true},
+		{"nested", InPath{Paths: []string{www}}, filepath.Join(www, "site"), true},
+		{"sibling with prefix", InPath{Paths: []string{www}}, filepath.Join(real, "www-old"), false},
+		{"nested in sibling with prefix", InPath{Paths: []string{www}}, filepath.Join(real, "www-old", "site"), false},
+		{"parent", InPath{Paths: []string{www}}, real, false},
+		{"trailing slash", InPath{Paths: []string{www + string(filepath.Separator)}}, filepath.Join(www, "site"), true},
+		{"trailing slash and sibling with prefix", InPath{Paths: []string{www + string(filepath.Separator)}}, filepath.Join(real, "www-old"), false},
+		{"any of", InPath{Paths: []string{filepath.Join(real, "other"), www}}, www, true},
+		{"relative path", InPath{Paths: []string{"src"}}, filepath.Join(root, "work", "src", "pkg"), true},
+		{"relative dir", InPath{Paths: []string{filepath.Join(root, "work")}}, "src/pkg", true},
+		{"relative dir outside", InPath{Paths: []string{www}}, "src/pkg", false},
+		{"relative path and dir", InPath{Paths: []string{"./src/"}}, "src/pkg", true},
+		{"relative path with prefix", InPath{Paths: []string{"sr"}}, "src/pkg", false},
+		{"glob", InPath{Paths: []string{filepath.Join(real, "www*")}}, filepath.Join(real, "www-old", "site"), true},
+		{"glob of a parent", InPath{Paths: []string{filepath.Join(real, "*", "site")}}, filepath.Join(www, "site"), true},
+		{"glob without match", InPath{Paths: []string{filepath.Join(real, "*", "pkg")}}, filepath.Join(www, "site"), false},
+		{"exclude", InPath{Paths: []string{www}, Exclude: []string{filepath.Join(www, "old")}}, filepath.Join(www, "old"), false},
+		{"exclude other", InPath{Paths: []string{www}, Exclude: []string{filepath.Join(www, "old")}}, filepath.Join(www, "site"), true},
+		{"exclude with prefix", InPath{Paths: []string{real}, Exclude: []string{www}}, filepath.Join(real, "www-old"), true},
+		{"exclude glob", InPath{Paths: []string{real}, Exclude: []string{filepath.Join(real, "www-*")}}, filepath.Join(real, "www-old", "site"), false},
+		{"symlinked dir", InPath{Paths: []string{www}}, filepath.Join(link, "www"), false},
+		{"symlinked dir resolved", InPath{Paths: []string{www}, ResolveSymlinks: true}, filepath.Join(link, "www", "site"), true},
+		{"symlinked path resolved", InPath{Paths: []string{filepath.Join(link, "www")}, ResolveSymlinks: true}, filepath.Join(www, "site"), true},
+		{"symlinked dir resolved and excluded", InPath{Paths: []string{real}, Exclude: []string{www}, ResolveSymlinks: true}, filepath.Join(link, "www"), false},
+		{"missing path", InPath{Paths: []string{filepath.Join(root, "missing")}, ResolveSymlinks: true}, filepath.Join(root, "missing", "dir"), true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.rule.Evaluate(test.dir)
+			if err != nil {
+				t.Fatalf("Evaluate() error: %s", err)
+			}
+
+			if got != test.want {
+				t.Errorf("Evaluate() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
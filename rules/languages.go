@@ -0,0 +1,347 @@
+package rules
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/mecha/tags/log"
+)
+
+type (
+	// Languages matches directories by counting the files of each language
+	// in them. A language matches if it has at least MinFiles files, and
+	// makes up at least MinPercent of the files with a known language.
+	Languages struct {
+		Languages  []string
+		MinFiles   int
+		MinPercent float64
+		MaxDepth   int
+		MaxFiles   int
+		Ignore     []string
+	}
+)
+
+func init() {
+	register("languages", "Matches on the languages of the files in the directory.", func() Rule { return &Languages{} },
+		Field{Name: "languages", Type: ListField, Description: "The languages to look for.", Required: true},
+		Field{Name: "min_files", Type: IntField, Description: "The minimum number of files in a language."},
+		Field{Name: "min_percent", Type: NumberField, Description: "The minimum percentage of files in a language."},
+		Field{Name: "max_depth", Type: IntField, Description: "How many directories deep to look."},
+		Field{Name: "max_files", Type: IntField, Description: "The maximum number of files to look at."},
+		Field{Name: "ignore", Type: ListField, Description: "Globs of files and directories to skip.", Expand: true},
+	)
+}
+
+const (
+	defaultLanguagesMaxDepth = 4
+	defaultLanguagesMaxFiles = 2000
+)
+
+// languageExts maps file extensions to language names.
+var languageExts = map[string]string{
+	".c":     "C",
+	".h":     "C",
+	".cc":    "C++",
+	".cpp":   "C++",
+	".cxx":   "C++",
+	".hpp":   "C++",
+	".cs":    "C#",
+	".clj":   "Clojure",
+	".css":   "CSS",
+	".scss":  "CSS",
+	".dart":  "Dart",
+	".ex":    "Elixir",
+	".exs":   "Elixir",
+	".elm":   "Elm",
+	".erl":   "Erlang",
+	".go":    "Go",
+	".hs":    "Haskell",
+	".html":  "HTML",
+	".htm":   "HTML",
+	".java":  "Java",
+	".js":    "JavaScript",
+	".mjs":   "JavaScript",
+	".cjs":   "JavaScript",
+	".jsx":   "JavaScript",
+	".kt":    "Kotlin",
+	".kts":   "Kotlin",
+	".lua":   "Lua",
+	".md":    "Markdown",
+	".nix":   "Nix",
+	".ml":    "OCaml",
+	".mli":   "OCaml",
+	".pl":    "Perl",
+	".pm":    "Perl",
+	".php":   "PHP",
+	".ps1":   "PowerShell",
+	".py":    "Python",
+	".r":     "R",
+	".rb":    "Ruby",
+	".rs":    "Rust",
+	".scala": "Scala",
+	".sh":    "Shell",
+	".bash":  "Shell",
+	".zsh":   "Shell",
+	".fish":  "Shell",
+	".sql":   "SQL",
+	".swift": "Swift",
+	".tex":   "TeX",
+	".sty":   "TeX",
+	".cls":   "TeX",
+	".bib":   "TeX",
+	".ts":    "TypeScript",
+	".tsx":   "TypeScript",
+	".vim":   "Vim Script",
+	".vue":   "Vue",
+	".zig":   "Zig",
+}
+
+// languageInterpreters maps the interpreters of shebang lines to language
+// names. Version suffixes, as in "python3", are ignored.
+var languageInterpreters = map[string]string{
+	"sh":      "Shell",
+	"bash":    "Shell",
+	"zsh":     "Shell",
+	"dash":    "Shell",
+	"ksh":     "Shell",
+	"fish":    "Shell",
+	"python":  "Python",
+	"node":    "JavaScript",
+	"deno":    "TypeScript",
+	"ruby":    "Ruby",
+	"perl":    "Perl",
+	"php":     "PHP",
+	"lua":     "Lua",
+	"Rscript": "R",
+}
+
+func (r *Languages) Load(cfg map[string]interface{}) error {
+	var err error
+
+	r.Languages, err = loadList("languages", "languages", cfg)
+	if err != nil {
+		return err
+	} else if r.Languages == nil {
+		return fmt.Errorf("[languages] no \"languages\" key in config\n")
+	}
+
+	if r.Ignore, err = loadList("languages", "ignore", cfg); err != nil {
+		return err
+	}
+
+	if err = loadInt("languages", "min_files", cfg, &r.MinFiles); err != nil {
+		return err
+	}
+	if err = loadNumber("languages", "min_percent", cfg, &r.MinPercent); err != nil {
+		return err
+	}
+	if err = loadInt("languages", "max_depth", cfg, &r.MaxDepth); err != nil {
+		return err
+	}
+	if err = loadInt("languages", "max_files", cfg, &r.MaxFiles); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *Languages) GetConfig() map[string]interface{} {
+	cfg := map[string]interface{}{
+		"languages": r.Languages,
+	}
+
+	if r.MinFiles > 0 {
+		cfg["min_files"] = r.MinFiles
+	}
+	if r.MinPercent > 0 {
+		cfg["min_percent"] = r.MinPercent
+	}
+	if r.MaxDepth > 0 {
+		cfg["max_depth"] = r.MaxDepth
+	}
+	if r.MaxFiles > 0 {
+		cfg["max_files"] = r.MaxFiles
+	}
+	if len(r.Ignore) > 0 {
+		cfg["ignore"] = r.Ignore
+	}
+
+	return cfg
+}
+
+func (r *Languages) Evaluate(dir string) (bool, error) {
+	minFiles := r.MinFiles
+	if minFiles <= 0 {
+		minFiles = 1
+	}
+
+	w := walker{
+		MaxDepth: r.MaxDepth,
+		MaxFiles: r.MaxFiles,
+		Ignore:   r.Ignore,
+	}
+
+	if w.MaxDepth <= 0 {
+		w.MaxDepth = defaultLanguagesMaxDepth
+	}
+	if w.MaxFiles <= 0 {
+		w.MaxFiles = defaultLanguagesMaxFiles
+	}
+
+	wanted := make(map[string]bool, len(r.Languages))
+	for _, lang := range r.Languages {
+		wanted[strings.ToLower(lang)] = true
+	}
+
+	counts := make(map[string]int)
+	total := 0
+	found := false
+
+	err := w.walk(dir, func(rel string, d fs.DirEntry) error {
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		lang := detectLanguage(filepath.Join(dir, filepath.FromSlash(rel)))
+		if lang == "" {
+			return nil
+		}
+
+		lang = strings.ToLower(lang)
+		counts[lang]++
+		total++
+
+		// Without a percentage, there's no need to count the other files.
+		if r.MinPercent <= 0 && wanted[lang] && counts[lang] >= minFiles {
+			found = true
+			return errStopWalk
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return false, err
+	} else if found {
+		return true, nil
+	}
+
+	for _, lang := range r.Languages {
+		count := counts[strings.ToLower(lang)]
+		percent := 0.0
+		if total > 0 {
+			percent = float64(count) * 100 / float64(total)
+		}
+
+		log.Debug("   [languages] %s: %d files, %.1f%%\n", lang, count, percent)
+
+		if count >= minFiles && percent >= r.MinPercent {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// detectLanguage returns the language of a file based on its extension or,
+// for files without one, the interpreter in its shebang line.
+func detectLanguage(file string) string {
+	ext := strings.ToLower(path.Ext(file))
+	if ext != "" {
+		return languageExts[ext]
+	}
+
+	interp := readShebang(file)
+	if interp == "" {
+		return ""
+	}
+
+	return languageInterpreters[interp]
+}
+
+// readShebang returns the name of the interpreter in a file's shebang line.
+// Only the first line is read.
+func readShebang(file string) string {
+	f, err := os.Open(file)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	line, _ := bufio.NewReaderSize(f, 256).ReadSlice('\n')
+
+	return parseShebang(line)
+}
+
+// parseShebang returns the name of the interpreter in a shebang line, with
+// any version suffix (as in "python3") removed. The "env" command is
+// followed to the actual interpreter.
+func parseShebang(line []byte) string {
+	if !bytes.HasPrefix(line, []byte("#!")) {
+		return ""
+	}
+
+	if idx := bytes.IndexByte(line, '\n'); idx >= 0 {
+		line = line[:idx]
+	}
+
+	fields := strings.Fields(string(line[2:]))
+	if len(fields) == 0 {
+		return ""
+	}
+
+	interp := path.Base(fields[0])
+
+	if interp == "env" {
+		interp = ""
+
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") && !strings.Contains(field, "=") {
+				interp = path.Base(field)
+				break
+			}
+		}
+	}
+
+	return strings.TrimRight(interp, "0123456789.")
+}
+
+// tags add shell languages Shell
+func (r *Languages) Add(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("No languages specified.")
+	}
+
+	r.Languages = append(r.Languages, args...)
+
+	return nil
+}
+
+func (r *Languages) Del(args []string) error {
+	if len(args) == 0 {
+		r.Languages = make([]string, 0)
+	} else {
+		r.Languages = removeAll(r.Languages, args)
+	}
+
+	return nil
+}
+
+func (r *Languages) String() string {
+	s := fmt.Sprintf("[languages] %s", strings.Join(r.Languages, ", "))
+
+	if r.MinFiles > 1 {
+		s += fmt.Sprintf(", at least %d files", r.MinFiles)
+	}
+	if r.MinPercent > 0 {
+		s += fmt.Sprintf(", at least %g%%", r.MinPercent)
+	}
+
+	return s
+}
@@ -0,0 +1,79 @@
+package rules
+
+import (
+	"testing"
+)
+
+func TestLanguagesEvaluate(t *testing.T) {
+	dir := t.TempDir()
+
+	writeFiles(t, dir, map[string]string{
+		"main.go":             "package main\n",
+		"cmd/tool/tool.go":    "package main\n",
+		"scripts/build":       "#!/usr/bin/env bash\n",
+		"scripts/release":     "#!/usr/bin/python3 -u\n",
+		"README.md":           "# Project\n",
+		"lib/py/lib.py":       "",
+		"lib/py/util.py":      "",
+		"lib/py/other.py":     "",
+		"web/a/b/c/d/deep.ts": "",
+		"vendor/lib/Lib.java": "",
+		"Makefile":            "all:\n",
+		"notes.txt":           "",
+	})
+
+	tests := []struct {
+		name string
+		rule Languages
+		want bool
+	}{
+		{"by extension", Languages{Languages: []string{"Go"}}, true},
+		{"case insensitive", Languages{Languages: []string{"go"}}, true},
+		{"any of", Languages{Languages: []string{"Rust", "Markdown"}}, true},
+		{"missing", Languages{Languages: []string{"Rust"}}, false},
+		{"by shebang", Languages{Languages: []string{"Shell"}}, true},
+		{"by shebang with version", Languages{Languages: []string{"Python"}, Ignore: []string{"lib"}}, true},
+		{"min files", Languages{Languages: []string{"Go"}, MinFiles: 2}, true},
+		{"below min files", Languages{Languages: []string{"Go"}, MinFiles: 3}, false},
+		{"min percent", Languages{Languages: []string{"Python"}, MinPercent: 50}, true},
+		{"below min percent", Languages{Languages: []string{"Go"}, MinPercent: 50}, false},
+		{"ignored", Languages{Languages: []string{"Python"}, MinFiles: 2, Ignore: []string{"lib"}}, false},
+		{"ignored glob", Languages{Languages: []string{"Python"}, MinFiles: 2, Ignore: []string{"lib/**"}}, false},
+		{"beyond default depth", Languages{Languages: []string{"TypeScript"}}, false},
+		{"max depth", Languages{Languages: []string{"TypeScript"}, MaxDepth: 6}, true},
+		{"max depth too low", Languages{Languages: []string{"Go"}, MinFiles: 2, MaxDepth: 1}, false},
+		{"vendored", Languages{Languages: []string{"Java"}}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.rule.Evaluate(dir)
+			if err != nil {
+				t.Fatalf("Evaluate() error: %s", err)
+			}
+
+			if got != test.want {
+				t.Errorf("Evaluate() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseShebang(t *testing.T) {
+	tests := map[string]string{
+		"#!/bin/sh\n":                    "sh",
+		"#!/usr/bin/env python3\n":       "python",
+		"#!/usr/bin/env -S deno run\n":   "deno",
+		"#!/usr/bin/env FOO=1 node\n":    "node",
+		"#! /usr/local/bin/ruby2.7 -w\n": "ruby",
+		"#!/usr/bin/env\n":               "",
+		"#!\n":                           "",
+		"package main\n":                 "",
+	}
+
+	for line, want := range tests {
+		if got := parseShebang([]byte(line)); got != want {
+			t.Errorf("parseShebang(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
@@ -0,0 +1,436 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/mecha/tags/log"
+)
+
+type (
+	// License matches directories by the license of their contents. The
+	// licenses are SPDX identifiers, which may use globs, such as "GPL-*", or
+	// license categories, such as "permissive" or "copyleft".
+	License struct {
+		Licenses []string
+	}
+
+	licenseInfo struct {
+		id       string
+		category string
+		snippets []string
+	}
+)
+
+func init() {
+	register("license", "Matches on the directory's license.", func() Rule { return &License{} },
+		Field{Name: "licenses", Type: ListField, Description: "SPDX license IDs or categories, such as \"MIT\" or \"permissive\".", Required: true},
+	)
+}
+
+const (
+	// The minimum fraction of a reference snippet's word sequences that must
+	// be found in a file for it to be identified as that license.
+	licenseThreshold = 0.8
+
+	// License files larger than this are only read up to this size.
+	licenseMaxRead = 128 * 1024
+)
+
+// knownLicenses contains a distinctive excerpt of the text of each license,
+// which is compared against license files after normalizing both.
+var knownLicenses = []licenseInfo{
+	{"MIT", "permissive", []string{`
+		Permission is hereby granted, free of charge, to any person obtaining a
+		copy of this software and associated documentation files (the
+		"Software"), to deal in the Software without restriction, including
+		without limitation the rights to use, copy, modify, merge, publish,
+		distribute, sublicense, and/or sell copies of the Software, and to
+		permit persons to whom the Software is furnished to do so, subject to
+		the following conditions: The above copyright notice and this
+		permission notice shall be included in all copies or substantial
+		portions of the Software.`,
+	}},
+	{"ISC", "permissive", []string{`
+		Permission to use, copy, modify, and/or distribute this software for
+		any purpose with or without fee is hereby granted, provided that the
+		above copyright notice and this permission notice appear in all
+		copies. THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL
+		WARRANTIES WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED
+		WARRANTIES OF MERCHANTABILITY AND FITNESS.`,
+	}},
+	{"BSD-2-Clause", "permissive", []string{`
+		Redistribution and use in source and binary forms, with or without
+		modification, are permitted provided that the following conditions are
+		met: Redistributions of source code must retain the above copyright
+		notice, this list of conditions and the following disclaimer.
+		Redistributions in binary form must reproduce the above copyright
+		notice, this list of conditions and the following disclaimer in the
+		documentation and/or other materials provided with the distribution.`,
+	}},
+	{"BSD-3-Clause", "permissive", []string{`
+		Redistribution and use in source and binary forms, with or without
+		modification, are permitted provided that the following conditions are
+		met: Redistributions of source code must retain the above copyright
+		notice, this list of conditions and the following disclaimer.
+		Redistributions in binary form must reproduce the above copyright
+		notice, this list of conditions and the following disclaimer in the
+		documentation and/or other materials provided with the distribution.
+		Neither the name of the copyright holder nor the names of its
+		contributors may be used to endorse or promote products derived from
+		this software without specific prior written permission.`,
+	}},
+	{"Apache-2.0", "permissive", []string{`
+		Apache License Version 2.0, January 2004
+		http://www.apache.org/licenses/ TERMS AND CONDITIONS FOR USE,
+		REPRODUCTION, AND DISTRIBUTION 1. Definitions. "License" shall mean
+		the terms and conditions for use, reproduction, and distribution as
+		defined by Sections 1 through 9 of this document.`, `
+		Licensed under the Apache License, Version 2.0 (the "License"); you may
+		not use this file except in compliance with the License. You may obtain
+		a copy of the License at http://www.apache.org/licenses/LICENSE-2.0`,
+	}},
+	{"Unlicense", "permissive", []string{`
+		This is free and unencumbered software released into the public domain.
+		Anyone is free to copy, modify, publish, use, compile, sell, or
+		distribute this software, either in source code form or as a compiled
+		binary, for any purpose, commercial or non-commercial, and by any
+		means.`,
+	}},
+	{"MPL-2.0", "weak-copyleft", []string{`
+		Mozilla Public License Version 2.0 1. Definitions 1.1. "Contributor"
+		means each individual or legal entity that creates, contributes to the
+		creation of, or owns Covered Software.`,
+	}},
+	{"LGPL-2.1", "weak-copyleft", []string{`
+		GNU LESSER GENERAL PUBLIC LICENSE Version 2.1, February 1999 Copyright
+		(C) 1991, 1999 Free Software Foundation, Inc. Everyone is permitted to
+		copy and distribute verbatim copies of this license document, but
+		changing it is not allowed. [This is the first released version of the
+		Lesser GPL. It also counts as the successor of the GNU Library Public
+		License, version 2, hence the version number 2.1.]`,
+	}},
+	{"LGPL-3.0", "weak-copyleft", []string{`
+		GNU LESSER GENERAL PUBLIC LICENSE Version 3, 29 June 2007 Copyright (C)
+		2007 Free Software Foundation, Inc. Everyone is permitted to copy and
+		distribute verbatim copies of this license document, but changing it is
+		not allowed. This version of the GNU Lesser General Public License
+		incorporates the terms and conditions of version 3 of the GNU General
+		Public License, supplemented by the additional permissions listed
+		below.`,
+	}},
+	{"GPL-2.0", "copyleft", []string{`
+		GNU GENERAL PUBLIC LICENSE Version 2, June 1991 Copyright (C) 1989,
+		1991 Free Software Foundation, Inc. Everyone is permitted to copy and
+		distribute verbatim copies of this license document, but changing it is
+		not allowed. Preamble The licenses for most software are designed to
+		take away your freedom to share and change it. By contrast, the GNU
+		General Public License is intended to guarantee your freedom to share
+		and change free software--to make sure the software is free for all its
+		users.`,
+	}},
+	{"GPL-3.0", "copyleft", []string{`
+		GNU GENERAL PUBLIC LICENSE Version 3, 29 June 2007 Copyright (C) 2007
+		Free Software Foundation, Inc. Everyone is permitted to copy and
+		distribute verbatim copies of this license document, but changing it is
+		not allowed. Preamble The GNU General Public License is a free, copyleft
+		license for software and other kinds of works.`,
+	}},
+	{"AGPL-3.0", "copyleft", []string{`
+		GNU AFFERO GENERAL PUBLIC LICENSE Version 3, 19 November 2007 Copyright
+		(C) 2007 Free Software Foundation, Inc. Everyone is permitted to copy
+		and distribute verbatim copies of this license document, but changing
+		it is not allowed. Preamble The GNU Affero General Public License is a
+		free, copyleft license for software and other kinds of works,
+		specifically designed to ensure cooperation with the community in the
+		case of network server software.`,
+	}},
+}
+
+// The prefixes of the names of license files, in lowercase.
+var licenseFilePrefixes = []string{"license", "licence", "copying", "unlicense"}
+
+var (
+	// Matches the "license" field of Cargo.toml and pyproject.toml files.
+	tomlLicenseRegex = regexp.MustCompile(`(?m)^\s*license\s*=\s*(?:\{\s*text\s*=\s*)?"([^"]+)"`)
+
+	// Splits SPDX expressions, such as "MIT OR Apache-2.0", into identifiers.
+	spdxSplitRegex = regexp.MustCompile(`\s+(?:OR|AND|WITH)\s+|[()]`)
+)
+
+func (r *License) Load(cfg map[string]interface{}) error {
+	var err error
+
+	r.Licenses, err = loadList("license", "licenses", cfg)
+	if err != nil {
+		return err
+	} else if r.Licenses == nil {
+		return fmt.Errorf("[license] no \"licenses\" key in config\n")
+	}
+
+	return nil
+}
+
+func (r *License) GetConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"licenses": r.Licenses,
+	}
+}
+
+func (r *License) Evaluate(dir string) (bool, error) {
+	found, err := detectLicenses(dir)
+	if err != nil {
+		return false, err
+	}
+
+	log.Debug("   [license] found: %s\n", strings.Join(found, ", "))
+
+	for _, pattern := range r.Licenses {
+		pattern = strings.ToLower(pattern)
+
+		for _, id := range found {
+			if pattern == licenseCategory(id) {
+				return true, nil
+			} else if match, _ := path.Match(pattern, strings.ToLower(id)); match {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// licenseCategory returns the category of a license, such as "permissive".
+func licenseCategory(id string) string {
+	for _, info := range knownLicenses {
+		if info.id == id {
+			return info.category
+		}
+	}
+
+	return ""
+}
+
+// detectLicenses returns the SPDX identifiers of the licenses in a directory,
+// found in license files and package metadata files.
+func detectLicenses(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]bool)
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+
+		name := strings.ToLower(entry.Name())
+		file := filepath.Join(dir, entry.Name())
+
+		switch name {
+		case "package.json", "composer.json":
+			for _, id := range readJSONLicense(file) {
+				found[id] = true
+			}
+			continue
+		case "cargo.toml", "pyproject.toml":
+			for _, id := range readTOMLLicense(file) {
+				found[id] = true
+			}
+			continue
+		}
+
+		for _, prefix := range licenseFilePrefixes {
+			if strings.HasPrefix(name, prefix) {
+				if id := identifyLicenseFile(file); id != "" {
+					found[id] = true
+				}
+				break
+			}
+		}
+	}
+
+	result := make([]string, 0, len(found))
+	for id := range found {
+		result = append(result, id)
+	}
+
+	sort.Strings(result)
+
+	return result, nil
+}
+
+// identifyLicenseFile returns the SPDX identifier of the license whose text
+// is most similar to the file's, if any is similar enough.
+func identifyLicenseFile(file string) string {
+	f, err := os.Open(file)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	text, err := io.ReadAll(io.LimitReader(f, licenseMaxRead))
+	if err != nil {
+		return ""
+	}
+
+	words := normalizeLicenseText(string(text))
+	trigrams := make(map[string]bool, len(words))
+	for i := 0; i+2 < len(words); i++ {
+		trigrams[words[i]+" "+words[i+1]+" "+words[i+2]] = true
+	}
+
+	bestID, bestScore, bestHits := "", 0.0, 0
+
+	for _, info := range knownLicenses {
+		for _, snippet := range info.snippets {
+			snippetWords := normalizeLicenseText(snippet)
+			total, hits := 0, 0
+
+			for i := 0; i+2 < len(snippetWords); i++ {
+				total++
+				if trigrams[snippetWords[i]+" "+snippetWords[i+1]+" "+snippetWords[i+2]] {
+					hits++
+				}
+			}
+
+			score := float64(hits) / float64(total)
+
+			// Among the similar enough licenses, prefer the one with the most
+			// matching text, since some licenses extend others, such as
+			// BSD-3-Clause with BSD-2-Clause.
+			if score >= licenseThreshold && hits > bestHits {
+				bestID, bestScore, bestHits = info.id, score, hits
+			}
+		}
+	}
+
+	log.Debug("   [license] %s: %s (%.2f)\n", filepath.Base(file), bestID, bestScore)
+
+	return bestID
+}
+
+// normalizeLicenseText lowercases a text and splits it into words, ignoring
+// punctuation, so that formatting differences do not affect comparisons.
+func normalizeLicenseText(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func readJSONLicense(file string) []string {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+
+	var pkg struct {
+		License interface{} `json:"license"`
+	}
+
+	if json.Unmarshal(raw, &pkg) != nil {
+		return nil
+	}
+
+	switch license := pkg.License.(type) {
+	case string:
+		return parseSPDX(license)
+	case []interface{}:
+		ids := make([]string, 0)
+		for _, item := range license {
+			if str, ok := item.(string); ok {
+				ids = append(ids, parseSPDX(str)...)
+			}
+		}
+		return ids
+	case map[string]interface{}:
+		if str, ok := license["type"].(string); ok {
+			return parseSPDX(str)
+		}
+	}
+
+	return nil
+}
+
+func readTOMLLicense(file string) []string {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+
+	match := tomlLicenseRegex.FindSubmatch(raw)
+	if match == nil {
+		return nil
+	}
+
+	return parseSPDX(string(match[1]))
+}
+
+// parseSPDX splits an SPDX license expression into license identifiers,
+// normalizing the version suffixes, as in "GPL-3.0-or-later", to the base
+// license. Identifiers are converted to the casing of the known licenses.
+func parseSPDX(expr string) []string {
+	ids := make([]string, 0)
+
+	for _, part := range spdxSplitRegex.Split(expr, -1) {
+		id := strings.TrimSpace(part)
+		id = strings.TrimSuffix(id, "+")
+		id = strings.TrimSuffix(id, "-only")
+		id = strings.TrimSuffix(id, "-or-later")
+
+		if id == "" {
+			continue
+		}
+
+		for _, info := range knownLicenses {
+			if strings.EqualFold(info.id, id) {
+				id = info.id
+			}
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+// tags add gpl license "GPL-*"
+// tags add permissive license permissive
+func (r *License) Add(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("No licenses specified.")
+	}
+
+	r.Licenses = append(r.Licenses, args...)
+
+	return nil
+}
+
+func (r *License) Del(args []string) error {
+	if len(args) == 0 {
+		r.Licenses = make([]string, 0)
+	} else {
+		r.Licenses = removeAll(r.Licenses, args)
+	}
+
+	return nil
+}
+
+func (r *License) String() string {
+	s := ""
+	for _, license := range r.Licenses {
+		s += fmt.Sprintf("\n[license] %s", license)
+	}
+
+	return strings.TrimLeft(s, "\n")
+}
@@ -0,0 +1,111 @@
+package rules
+
+import (
+	"reflect"
+	"testing"
+)
+
+const mitLicense = `MIT License
+
+Copyright (c) 2024 Someone
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+`
+
+const bsd3License = `Copyright (c) 2024, Someone
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+1. Redistributions of source code must retain the above copyright notice, this
+   list of conditions and the following disclaimer.
+
+2. Redistributions in binary form must reproduce the above copyright notice,
+   this list of conditions and the following disclaimer in the documentation
+   and/or other materials provided with the distribution.
+
+3. Neither the name of the copyright holder nor the names of its
+   contributors may be used to endorse or promote products derived from
+   this software without specific prior written permission.
+`
+
+func TestDetectLicenses(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+	}{
+		{"license file", map[string]string{"LICENSE": mitLicense}, []string{"MIT"}},
+		{"license file with extension", map[string]string{"LICENSE.md": mitLicense}, []string{"MIT"}},
+		{"license that extends another", map[string]string{"COPYING": bsd3License}, []string{"BSD-3-Clause"}},
+		{"unknown text", map[string]string{"LICENSE": "All rights reserved.\n"}, []string{}},
+		{"other file", map[string]string{"README": mitLicense}, []string{}},
+		{"package.json", map[string]string{"package.json": `{"license": "ISC"}`}, []string{"ISC"}},
+		{"package.json expression", map[string]string{"package.json": `{"license": "(MIT OR Apache-2.0)"}`}, []string{"Apache-2.0", "MIT"}},
+		{"Cargo.toml", map[string]string{"Cargo.toml": "[package]\nname = \"x\"\nlicense = \"MIT OR Apache-2.0\"\n"}, []string{"Apache-2.0", "MIT"}},
+		{"pyproject.toml table", map[string]string{"pyproject.toml": "[project]\nlicense = {text = \"GPL-3.0\"}\n"}, []string{"GPL-3.0"}},
+		{"several", map[string]string{"LICENSE": mitLicense, "package.json": `{"license": "MIT"}`, "COPYING": bsd3License}, []string{"BSD-3-Clause", "MIT"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, test.files)
+
+			got, err := detectLicenses(dir)
+			if err != nil {
+				t.Fatalf("detectLicenses() error: %s", err)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("detectLicenses() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLicenseEvaluate(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"LICENSE":      mitLicense,
+		"package.json": `{"license": "GPL-3.0-only"}`,
+	})
+
+	tests := []struct {
+		name     string
+		licenses []string
+		want     bool
+	}{
+		{"id", []string{"MIT"}, true},
+		{"id case insensitive", []string{"mit"}, true},
+		{"other id", []string{"Apache-2.0"}, false},
+		{"glob", []string{"GPL-*"}, true},
+		{"glob without match", []string{"LGPL-*"}, false},
+		{"category", []string{"permissive"}, true},
+		{"other category", []string{"weak-copyleft"}, false},
+		{"any of", []string{"Apache-2.0", "MIT"}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule := License{Licenses: test.licenses}
+
+			got, err := rule.Evaluate(dir)
+			if err != nil {
+				t.Fatalf("Evaluate() error: %s", err)
+			}
+
+			if got != test.want {
+				t.Errorf("Evaluate() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package rules
+
+import (
+	"fmt"
+	"sort"
+)
+
+// loadList reads a list of strings from a rule's config. Missing keys yield a
+// nil list, so that callers can decide whether the key is required.
+func loadList(rType string, key string, cfg map[string]interface{}) ([]string, error) {
+	val, ok := cfg[key]
+	if !ok {
+		return nil, nil
+	}
+
+	list, ok := val.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("[%s] \"%s\" is not a list: %v", rType, key, val)
+	}
+
+	result := make([]string, 0, len(list))
+
+	for _, item := range list {
+		str, ok := item.(string)
+
+		if !ok {
+			return nil, fmt.Errorf("[%s] invalid value in \"%s\": %v", rType, key, item)
+		}
+
+		result = append(result, str)
+	}
+
+	return result, nil
+}
+
+// loadMap reads an object of strings from a rule's config. Missing keys yield
+// a nil map.
+func loadMap(rType string, key string, cfg map[string]interface{}) (map[string]string, error) {
+	val, ok := cfg[key]
+	if !ok {
+		return nil, nil
+	}
+
+	dict, ok := val.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("[%s] \"%s\" is not an object: %v", rType, key, val)
+	}
+
+	result := make(map[string]string, len(dict))
+
+	for k, v := range dict {
+		str, ok := v.(string)
+
+		if !ok {
+			return nil, fmt.Errorf("[%s] invalid value for \"%s\" in \"%s\": %v", rType, k, key, v)
+		}
+
+		result[k] = str
+	}
+
+	return result, nil
+}
+
+// sortedKeys returns the keys of a map in lexical order, for stable output.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// removeAll returns list without any occurrences of the given values.
+func removeAll(list []string, values []string) []string {
+	result := make([]string, 0, len(list))
+
+	for _, item := range list {
+		keep := true
+
+		for _, v := range values {
+			if item == v {
+				keep = false
+				break
+			}
+		}
+
+		if keep {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
+
+// loadNumber reads a number from a rule's config into ptr, leaving it
+// untouched if the key is missing.
+func loadNumber(rType string, key string, cfg map[string]interface{}, ptr *float64) error {
+	val, ok := cfg[key]
+	if !ok {
+		return nil
+	}
+
+	num, ok := val.(float64)
+	if !ok {
+		return fmt.Errorf("[%s] \"%s\" is not a number: %v", rType, key, val)
+	}
+
+	*ptr = num
+
+	return nil
+}
+
+// loadInt reads a whole number from a rule's config into ptr, leaving it
+// untouched if the key is missing.
+func loadInt(rType string, key string, cfg map[string]interface{}, ptr *int) error {
+	num := float64(*ptr)
+
+	if err := loadNumber(rType, key, cfg, &num); err != nil {
+		return err
+	}
+
+	if num != float64(int(num)) {
+		return fmt.Errorf("[%s] \"%s\" is not a whole number: %v", rType, key, num)
+	}
+
+	*ptr = int(num)
+
+	return nil
+}
+
+// contains reports whether a list contains a value.
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,198 @@
+package rules
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mecha/tags/log"
+	"github.com/mecha/tags/utils"
+)
+
+type (
+	// ModifiedWithin matches directories that have a file that was modified
+	// within a duration, such as "7d". If Files is empty, all the files in
+	// the directory are checked, else only the listed files and globs.
+	ModifiedWithin struct {
+		Duration string
+		Files    []string
+		MaxDepth int
+		MaxFiles int
+
+		// Now returns the current time. If nil, time.Now is used.
+		Now func() time.Time
+	}
+)
+
+func init() {
+	register("modified_within", "Matches if files were modified recently.", func() Rule { return &ModifiedWithin{} },
+		Field{Name: "duration", Type: StringField, Description: "How recently, such as \"12h\" or \"7d\".", Required: true},
+		Field{Name: "files", Type: ListField, Description: "Specific files to check, instead of all files.", Expand: true},
+		Field{Name: "max_depth", Type: IntField, Description: "How many directories deep to look."},
+		Field{Name: "max_files", Type: IntField, Description: "The maximum number of files to look at."},
+	)
+}
+
+const (
+	defaultModifiedMaxDepth = 3
+	defaultModifiedMaxFiles = 5000
+)
+
+func (r *ModifiedWithin) Load(cfg map[string]interface{}) error {
+	val, ok := cfg["duration"]
+	if !ok {
+		return fmt.Errorf("[modified_within] no \"duration\" key in config\n")
+	}
+
+	if r.Duration, ok = val.(string); !ok {
+		return fmt.Errorf("[modified_within] \"duration\" is not a string: %v", val)
+	}
+
+	if _, err := utils.ParseDuration(r.Duration); err != nil {
+		return fmt.Errorf("[modified_within] %s", err)
+	}
+
+	var err error
+
+	if r.Files, err = loadList("modified_within", "files", cfg); err != nil {
+		return err
+	}
+	if err = loadInt("modified_within", "max_depth", cfg, &r.MaxDepth); err != nil {
+		return err
+	}
+
+	return loadInt("modified_within", "max_files", cfg, &r.MaxFiles)
+}
+
+func (r *ModifiedWithin) GetConfig() map[string]interface{} {
+	cfg := map[string]interface{}{
+		"duration": r.Duration,
+	}
+
+	if len(r.Files) > 0 {
+		cfg["files"] = r.Files
+	}
+	if r.MaxDepth > 0 {
+		cfg["max_depth"] = r.MaxDepth
+	}
+	if r.MaxFiles > 0 {
+		cfg["max_files"] = r.MaxFiles
+	}
+
+	return cfg
+}
+
+func (r *ModifiedWithin) Evaluate(dir string) (bool, error) {
+	duration, err := utils.ParseDuration(r.Duration)
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now
+	if r.Now != nil {
+		now = r.Now
+	}
+
+	since := now().Add(-duration)
+	log.Debug("   [modified_within] %s (since %s)\n", r.Duration, since.Format(time.RFC3339))
+
+	globs := make([]string, 0)
+
+	for _, file := range r.Files {
+		if utils.HasGlobMeta(file) {
+			globs = append(globs, file)
+			continue
+		}
+
+		info, err := os.Stat(filepath.Join(dir, file))
+		if err == nil && info.ModTime().After(since) {
+			return true, nil
+		} else if err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+	}
+
+	// Only walk if there are globs, or no specific files to check.
+	if len(r.Files) > 0 && len(globs) == 0 {
+		return false, nil
+	}
+
+	w := walker{MaxDepth: r.MaxDepth, MaxFiles: r.MaxFiles, Include: globs}
+	if w.MaxDepth <= 0 {
+		w.MaxDepth = defaultModifiedMaxDepth
+	}
+	if w.MaxFiles <= 0 {
+		w.MaxFiles = defaultModifiedMaxFiles
+	}
+
+	patterns, err := compileGlobs(globs)
+	if err != nil {
+		return false, err
+	}
+
+	found := false
+
+	err = w.walk(dir, func(rel string, d fs.DirEntry) error {
+		if d.IsDir() || (len(patterns) > 0 && !patterns.matches(rel)) {
+			return nil
+		}
+
+		if info, err := d.Info(); err == nil && info.ModTime().After(since) {
+			log.Debug("   [modified_within] %s\n", rel)
+			found = true
+			return errStopWalk
+		}
+
+		return nil
+	})
+
+	return found, err
+}
+
+// The first argument that is a valid duration sets the rule's duration, and
+// all other arguments are added as files.
+//
+// tags add recent modified_within 7d
+// tags add active-git modified_within 2w .git/HEAD
+func (r *ModifiedWithin) Add(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("No duration or files specified.")
+	}
+
+	for _, arg := range args {
+		if _, err := utils.ParseDuration(arg); err == nil {
+			r.Duration = arg
+		} else {
+			r.Files = append(r.Files, arg)
+		}
+	}
+
+	if r.Duration == "" {
+		return fmt.Errorf("No duration specified.")
+	}
+
+	return nil
+}
+
+func (r *ModifiedWithin) Del(args []string) error {
+	if len(args) == 0 {
+		*r = ModifiedWithin{}
+	} else {
+		r.Files = removeAll(r.Files, args)
+	}
+
+	return nil
+}
+
+func (r *ModifiedWithin) String() string {
+	s := fmt.Sprintf("[modified_within] %s", r.Duration)
+
+	if len(r.Files) > 0 {
+		s += ": " + strings.Join(r.Files, ", ")
+	}
+
+	return s
+}
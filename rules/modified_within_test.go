@@ -0,0 +1,64 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestModifiedWithinEvaluate(t *testing.T) {
+	modTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		files []string
+		now   time.Time
+		want  bool
+	}{
+		{"just inside", nil, modTime.Add(24*time.Hour - time.Nanosecond), true},
+		{"on the edge", nil, modTime.Add(24 * time.Hour), false},
+		{"outside", nil, modTime.Add(48 * time.Hour), false},
+		{"modified now", nil, modTime, true},
+		{"modified in the future", nil, modTime.Add(-time.Hour), true},
+		{"file just inside", []string{"src/main.go"}, modTime.Add(24*time.Hour - time.Nanosecond), true},
+		{"file on the edge", []string{"src/main.go"}, modTime.Add(24 * time.Hour), false},
+		{"missing file", []string{"src/other.go"}, modTime, false},
+		{"glob just inside", []string{"src/*.go"}, modTime.Add(24*time.Hour - time.Nanosecond), true},
+		{"glob on the edge", []string{"src/*.go"}, modTime.Add(24 * time.Hour), false},
+		{"glob without matches", []string{"*.py"}, modTime, false},
+	}
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "src", "main.go")
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(file, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			now := test.now
+			rule := ModifiedWithin{
+				Duration: "1d",
+				Files:    test.files,
+				Now:      func() time.Time { return now },
+			}
+
+			got, err := rule.Evaluate(dir)
+			if err != nil {
+				t.Fatalf("Evaluate() error: %s", err)
+			}
+
+			if got != test.want {
+				t.Errorf("Evaluate() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package rules
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/mecha/tags/log"
+)
+
+type (
+	// Mount matches directories that are on a filesystem of a specific type,
+	// such as "tmpfs", "nfs" or "fuse". Types may use globs.
+	Mount struct {
+		FSTypes []string
+	}
+)
+
+func init() {
+	register("mount", "Matches on the type of filesystem the directory is on.", func() Rule { return &Mount{} },
+		Field{Name: "fs_types", Type: ListField, Description: "Filesystem types, such as \"tmpfs\", \"nfs\" or \"fuse\".", Required: true},
+	)
+}
+
+func (r *Mount) Load(cfg map[string]interface{}) error {
+	var err error
+
+	r.FSTypes, err = loadList("mount", "fs_types", cfg)
+	if err != nil {
+		return err
+	} else if r.FSTypes == nil {
+		return fmt.Errorf("[mount] no \"fs_types\" key in config\n")
+	}
+
+	return nil
+}
+
+func (r *Mount) GetConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"fs_types": r.FSTypes,
+	}
+}
+
+func (r *Mount) Evaluate(dir string) (bool, error) {
+	fsName, err := fsType(dir)
+	if err != nil {
+		log.Debug("   [mount] %s\n", err)
+		return false, nil
+	}
+
+	log.Debug("   [mount] %s\n", fsName)
+
+	for _, pattern := range r.FSTypes {
+		if match, err := path.Match(pattern, fsName); err != nil {
+			return false, err
+		} else if match {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// tags add remote mount nfs smb2 cifs fuse
+func (r *Mount) Add(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("No filesystem types specified.")
+	}
+
+	r.FSTypes = append(r.FSTypes, args...)
+
+	return nil
+}
+
+func (r *Mount) Del(args []string) error {
+	if len(args) == 0 {
+		r.FSTypes = make([]string, 0)
+	} else {
+		r.FSTypes = removeAll(r.FSTypes, args)
+	}
+
+	return nil
+}
+
+func (r *Mount) String() string {
+	s := ""
+	for _, fsName := range r.FSTypes {
+		s += fmt.Sprintf("\n[mount] %s", fsName)
+	}
+
+	return strings.TrimLeft(s, "\n")
+}
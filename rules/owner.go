@@ -0,0 +1,145 @@
+package rules
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+
+	"github.com/mecha/tags/log"
+)
+
+type (
+	// Owner matches directories that are owned by a user or group. Users and
+	// groups may be given by name or by numeric ID.
+	Owner struct {
+		Users  []string
+		Groups []string
+	}
+)
+
+func init() {
+	register("owner", "Matches on the user or group that owns the directory.", func() Rule { return &Owner{} },
+		Field{Name: "users", Type: ListField, Description: "Usernames or user IDs."},
+		Field{Name: "groups", Type: ListField, Description: "Group names or group IDs."},
+	)
+}
+
+func (r *Owner) Load(cfg map[string]interface{}) error {
+	var err error
+
+	if r.Users, err = loadList("owner", "users", cfg); err != nil {
+		return err
+	}
+	if r.Groups, err = loadList("owner", "groups", cfg); err != nil {
+		return err
+	}
+
+	if r.Users == nil && r.Groups == nil {
+		return fmt.Errorf("[owner] config needs at least one of \"users\" or \"groups\"\n")
+	}
+
+	return nil
+}
+
+func (r *Owner) GetConfig() map[string]interface{} {
+	cfg := map[string]interface{}{}
+
+	if len(r.Users) > 0 {
+		cfg["users"] = r.Users
+	}
+	if len(r.Groups) > 0 {
+		cfg["groups"] = r.Groups
+	}
+
+	return cfg
+}
+
+func (r *Owner) Evaluate(dir string) (bool, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return false, err
+	}
+
+	uid, gid, ok := fileOwner(info)
+	if !ok {
+		log.Debug("   [owner] file ownership is not supported\n")
+		return false, nil
+	}
+
+	userNames := []string{uid}
+	if u, err := user.LookupId(uid); err == nil {
+		userNames = append(userNames, u.Username)
+	}
+
+	groupNames := []string{gid}
+	if g, err := user.LookupGroupId(gid); err == nil {
+		groupNames = append(groupNames, g.Name)
+	}
+
+	log.Debug("   [owner] %s:%s\n", userNames[len(userNames)-1], groupNames[len(groupNames)-1])
+
+	for _, name := range r.Users {
+		if contains(userNames, name) {
+			return true, nil
+		}
+	}
+
+	for _, name := range r.Groups {
+		if contains(groupNames, name) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Groups are prefixed with ":", as in chown(1).
+//
+// tags add system owner root
+// tags add shared owner :staff
+func (r *Owner) Add(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("No users or groups specified.")
+	}
+
+	for _, arg := range args {
+		if strings.HasPrefix(arg, ":") {
+			r.Groups = append(r.Groups, arg[1:])
+		} else {
+			r.Users = append(r.Users, arg)
+		}
+	}
+
+	return nil
+}
+
+func (r *Owner) Del(args []string) error {
+	if len(args) == 0 {
+		r.Users = nil
+		r.Groups = nil
+		return nil
+	}
+
+	for _, arg := range args {
+		if strings.HasPrefix(arg, ":") {
+			r.Groups = removeAll(r.Groups, []string{arg[1:]})
+		} else {
+			r.Users = removeAll(r.Users, []string{arg})
+		}
+	}
+
+	return nil
+}
+
+func (r *Owner) String() string {
+	s := ""
+	for _, name := range r.Users {
+		s += fmt.Sprintf("\n[owner] %s", name)
+	}
+	for _, name := range r.Groups {
+		s += fmt.Sprintf("\n[owner] :%s", name)
+	}
+
+	return strings.TrimLeft(s, "\n")
+}
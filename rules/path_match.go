@@ -0,0 +1,184 @@
+package rules
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mecha/tags/log"
+	"github.com/mecha/tags/utils"
+)
+
+type (
+	// PathMatch matches directories by their name or by their full, absolute
+	// path. Patterns are globs, or regular expressions if prefixed with "re:".
+	PathMatch struct {
+		Names []string
+		Paths []string
+
+		// compiled holds the compiled patterns, so that they are not compiled
+		// again every time the rule is evaluated.
+		compiled map[string]*regexp.Regexp
+	}
+)
+
+func init() {
+	register("path_match", "Matches the directory's name or path against patterns.", func() Rule { return &PathMatch{} },
+		Field{Name: "names", Type: ListField, Description: "Globs, or regular expressions prefixed with \"re:\", for the directory's name."},
+		Field{Name: "paths", Type: ListField, Description: "Globs, or regular expressions prefixed with \"re:\", for the directory's path.", Expand: true},
+	)
+}
+
+const regexPrefix = "re:"
+
+func (r *PathMatch) Load(cfg map[string]interface{}) error {
+	var err error
+
+	if r.Names, err = loadList("path_match", "names", cfg); err != nil {
+		return err
+	}
+	if r.Paths, err = loadList("path_match", "paths", cfg); err != nil {
+		return err
+	}
+
+	if r.Names == nil && r.Paths == nil {
+		return fmt.Errorf("[path_match] config needs at least one of \"names\" or \"paths\"\n")
+	}
+
+	r.compiled = make(map[string]*regexp.Regexp, len(r.Names)+len(r.Paths))
+
+	for _, pattern := range append(append([]string{}, r.Names...), r.Paths...) {
+		re, err := compilePattern(pattern)
+		if err != nil {
+			return fmt.Errorf("[path_match] invalid pattern \"%s\": %s", pattern, err)
+		}
+
+		r.compiled[pattern] = re
+	}
+
+	return nil
+}
+
+func (r *PathMatch) GetConfig() map[string]interface{} {
+	cfg := map[string]interface{}{}
+
+	if len(r.Names) > 0 {
+		cfg["names"] = r.Names
+	}
+	if len(r.Paths) > 0 {
+		cfg["paths"] = r.Paths
+	}
+
+	return cfg
+}
+
+// compilePattern compiles a "re:" prefixed regular expression, or a glob.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if strings.HasPrefix(pattern, regexPrefix) {
+		return regexp.Compile(pattern[len(regexPrefix):])
+	}
+
+	return utils.CompileGlob(pattern)
+}
+
+// pattern returns a compiled pattern, compiling it if the rule was not
+// loaded from a config.
+func (r *PathMatch) pattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := r.compiled[pattern]; ok {
+		return re, nil
+	}
+
+	return compilePattern(pattern)
+}
+
+func (r *PathMatch) Evaluate(dir string) (bool, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return false, err
+	}
+
+	abs = filepath.ToSlash(abs)
+	name := filepath.Base(abs)
+
+	for _, pattern := range r.Names {
+		log.Debug("   [path_match] name %s\n", pattern)
+
+		re, err := r.pattern(pattern)
+		if err != nil {
+			return false, err
+		} else if re.MatchString(name) {
+			return true, nil
+		}
+	}
+
+	for _, pattern := range r.Paths {
+		log.Debug("   [path_match] path %s\n", pattern)
+
+		re, err := r.pattern(pattern)
+		if err != nil {
+			return false, err
+		} else if re.MatchString(abs) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Patterns that contain a "/" are matched against the full path, and all
+// other patterns against the directory's name.
+//
+// tags add infra path_match "*-infra"
+// tags add chart path_match "**/charts/*"
+func (r *PathMatch) Add(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("No patterns specified.")
+	}
+
+	if r.compiled == nil {
+		r.compiled = make(map[string]*regexp.Regexp)
+	}
+
+	for _, arg := range args {
+		re, err := compilePattern(arg)
+		if err != nil {
+			return fmt.Errorf("Invalid pattern \"%s\": %s", arg, err)
+		}
+
+		r.compiled[arg] = re
+
+		if strings.Contains(arg, "/") {
+			r.Paths = append(r.Paths, arg)
+		} else {
+			r.Names = append(r.Names, arg)
+		}
+	}
+
+	return nil
+}
+
+func (r *PathMatch) Del(args []string) error {
+	if len(args) == 0 {
+		r.Names = nil
+		r.Paths = nil
+		return nil
+	}
+
+	r.Names = removeAll(r.Names, args)
+	r.Paths = removeAll(r.Paths, args)
+
+	return nil
+}
+
+func (r *PathMatch) String() string {
+	s := ""
+	for _, name := range r.Names {
+		s += fmt.Sprintf("\n[path_match] name %s", name)
+	}
+	for _, path := range r.Paths {
+		s += fmt.Sprintf("\n[path_match] path %s", path)
+	}
+
+	return strings.TrimLeft(s, "\n")
+}
@@ -0,0 +1,71 @@
+package rules
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPathMatchEvaluate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "charts", "web-infra")
+
+	tests := []struct {
+		name string
+		cfg  map[string]interface{}
+		want bool
+	}{
+		{"name glob", map[string]interface{}{"names": []interface{}{"*-infra"}}, true},
+		{"name glob without match", map[string]interface{}{"names": []interface{}{"*-app"}}, false},
+		{"name regex", map[string]interface{}{"names": []interface{}{"re:^web-"}}, true},
+		{"name is not the path", map[string]interface{}{"names": []interface{}{"charts*"}}, false},
+		{"path glob", map[string]interface{}{"paths": []interface{}{"**/charts/*"}}, true},
+		{"path glob without match", map[string]interface{}{"paths": []interface{}{"**/charts"}}, false},
+		{"path regex", map[string]interface{}{"paths": []interface{}{"re:/charts/[^/]+$"}}, true},
+		{"any of", map[string]interface{}{"names": []interface{}{"*-app"}, "paths": []interface{}{"**/charts/*"}}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule := PathMatch{}
+			if err := rule.Load(test.cfg); err != nil {
+				t.Fatalf("Load() error: %s", err)
+			}
+
+			got, err := rule.Evaluate(dir)
+			if err != nil {
+				t.Fatalf("Evaluate() error: %s", err)
+			}
+
+			if got != test.want {
+				t.Errorf("Evaluate() = %v, want %v", got, test.want)
+			}
+
+			// Rules that are not loaded from a config compile their
+			// patterns when they are evaluated.
+			unloaded := PathMatch{Names: rule.Names, Paths: rule.Paths}
+
+			if got, err = unloaded.Evaluate(dir); err != nil || got != test.want {
+				t.Errorf("Evaluate() without Load() = %v, %v, want %v", got, err, test.want)
+			}
+		})
+	}
+}
+
+func TestPathMatchAdd(t *testing.T) {
+	rule := PathMatch{}
+
+	if err := rule.Add([]string{"*-infra", "**/charts/*"}); err != nil {
+		t.Fatalf("Add() error: %s", err)
+	}
+
+	if len(rule.Names) != 1 || len(rule.Paths) != 1 {
+		t.Errorf("Add() = %v, %v, want one name and one path", rule.Names, rule.Paths)
+	}
+
+	if got, err := rule.Evaluate(filepath.Join(t.TempDir(), "web-infra")); err != nil || !got {
+		t.Errorf("Evaluate() = %v, %v, want true", got, err)
+	}
+
+	if err := rule.Add([]string{"re:("}); err == nil {
+		t.Errorf("Add() of an invalid pattern did not fail")
+	}
+}
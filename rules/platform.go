@@ -0,0 +1,95 @@
+package rules
+
+import (
+	"fmt"
+	"path"
+	"runtime"
+	"strings"
+
+	"github.com/mecha/tags/log"
+)
+
+type (
+	// Platform matches the OS and architecture that the program runs on.
+	// Platforms are written as "GOOS" or "GOOS/GOARCH", and may use globs,
+	// such as "*/arm64".
+	Platform struct {
+		Platforms []string
+	}
+)
+
+func init() {
+	register("platform", "Matches on the operating system and architecture.", func() Rule { return &Platform{} },
+		Field{Name: "platforms", Type: ListField, Description: "Patterns such as \"linux\", \"darwin/arm64\" or \"*/amd64\".", Required: true},
+	)
+}
+
+func (r *Platform) Load(cfg map[string]interface{}) error {
+	var err error
+
+	r.Platforms, err = loadList("platform", "platforms", cfg)
+	if err != nil {
+		return err
+	} else if r.Platforms == nil {
+		return fmt.Errorf("[platform] no \"platforms\" key in config\n")
+	}
+
+	return nil
+}
+
+func (r *Platform) GetConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"platforms": r.Platforms,
+	}
+}
+
+func (r *Platform) Evaluate(dir string) (bool, error) {
+	for _, pattern := range r.Platforms {
+		log.Debug("   [platform] %s\n", pattern)
+
+		target := runtime.GOOS
+		if strings.Contains(pattern, "/") {
+			target += "/" + runtime.GOARCH
+		}
+
+		match, err := path.Match(pattern, target)
+		if err != nil {
+			return false, err
+		} else if match {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// tags add mac platform darwin
+// tags add arm platform "*/arm64"
+func (r *Platform) Add(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("No platforms specified.")
+	}
+
+	r.Platforms = append(r.Platforms, args...)
+
+	return nil
+}
+
+func (r *Platform) Del(args []string) error {
+	if len(args) == 0 {
+		r.Platforms = make([]string, 0)
+	} else {
+		r.Platforms = removeAll(r.Platforms, args)
+	}
+
+	return nil
+}
+
+func (r *Platform) String() string {
+	s := ""
+	for _, platform := range r.Platforms {
+		s += fmt.Sprintf("\n[platform] %s", platform)
+	}
+
+	return strings.TrimLeft(s, "\n")
+}
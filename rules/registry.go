@@ -0,0 +1,125 @@
+package rules
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+type (
+	// FieldType is the type of the value of a key in a rule's config.
+	FieldType string
+
+	// Field describes a key in a rule type's config.
+	Field struct {
+		Name        string
+		Type        FieldType
+		Description string
+		Required    bool
+		// Expand is set on fields that hold paths. Their values may contain
+		// environment variables, and may start with "~" or "~user". In a
+		// MapField, the keys hold the paths and are expanded instead.
+		Expand bool
+		// Fields describes the objects in a MapField whose values are objects
+		// rather than strings.
+		Fields []Field
+	}
+
+	// ruleType is a registered rule type.
+	ruleType struct {
+		name        string
+		description string
+		new         func() Rule
+		fields      []Field
+	}
+)
+
+const (
+	StringField FieldType = "string"
+	NumberField FieldType = "number"
+	IntField    FieldType = "integer"
+	BoolField   FieldType = "boolean"
+	// ListField is a list of strings.
+	ListField FieldType = "list"
+	// MapField is an object of strings, or of objects if the field has
+	// Fields.
+	MapField FieldType = "map"
+	// SizeField is a whole number or a size string, such as "10MB".
+	SizeField FieldType = "size"
+)
+
+var (
+	registry   = make(map[string]ruleType)
+	typeByImpl = make(map[reflect.Type]string)
+)
+
+// register adds a rule type. Each rule type registers itself from an init
+// function in its own file, along with the keys of its config.
+func register(name string, description string, new func() Rule, fields ...Field) {
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("rule type %s is registered twice", name))
+	}
+
+	registry[name] = ruleType{name, description, new, fields}
+	typeByImpl[reflect.TypeOf(new())] = name
+}
+
+// New creates an empty rule of the given type.
+func New(rType string) (Rule, error) {
+	t, ok := registry[rType]
+	if !ok {
+		return nil, fmt.Errorf("Unknown rule type: %s", rType)
+	}
+
+	return t.new(), nil
+}
+
+// GetType returns the type name of a rule, or an empty string if the rule's
+// type is not registered.
+func GetType(rule Rule) string {
+	return typeByImpl[reflect.TypeOf(rule)]
+}
+
+// Types returns the names of all rule types, in lexical order.
+func Types() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Describe returns the description of a rule type.
+func Describe(rType string) string {
+	return registry[rType].description
+}
+
+// Fields returns the keys that a rule type's config accepts, or nil if the
+// rule type is unknown.
+func Fields(rType string) []Field {
+	t, ok := registry[rType]
+	if !ok {
+		return nil
+	}
+
+	return t.fields
+}
+
+// Keys returns the names of the keys that a rule type's config accepts, or
+// nil if the rule type is unknown.
+func Keys(rType string) []string {
+	t, ok := registry[rType]
+	if !ok {
+		return nil
+	}
+
+	keys := make([]string, 0, len(t.fields))
+	for _, field := range t.fields {
+		keys = append(keys, field.Name)
+	}
+
+	return keys
+}
@@ -15,32 +15,6 @@ type (
 	}
 )
 
-func New(rType string) (Rule, error) {
-	switch rType {
-	case "file_exists":
-		return &FileExists{}, nil
-	case "file_contains":
-		return &FileContains{}, nil
-	case "in_path":
-		return &InPath{}, nil
-	default:
-		return nil, fmt.Errorf("Unknown rule type: %s", rType)
-	}
-}
-
-func GetType(rule Rule) string {
-	switch rule.(type) {
-	case *FileExists:
-		return "file_exists"
-	case *FileContains:
-		return "file_contains"
-	case *InPath:
-		return "in_path"
-	default:
-		return ""
-	}
-}
-
 func Add(rules []Rule, rType string, args []string) ([]Rule, error) {
 	for _, r := range rules {
 		if GetType(r) == rType {
@@ -0,0 +1,91 @@
+package rules
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mecha/tags/log"
+)
+
+type (
+	// Symlink matches directories where any of the files is a symbolic
+	// link. The file "." checks the directory itself.
+	Symlink struct {
+		Files []string
+	}
+)
+
+func init() {
+	register("symlink", "Matches if any of the files in the directory is a symlink.", func() Rule { return &Symlink{} },
+		Field{Name: "files", Type: ListField, Description: "The files to check. The file \".\" checks the directory itself.", Required: true, Expand: true},
+	)
+}
+
+func (r *Symlink) Load(cfg map[string]interface{}) error {
+	var err error
+
+	r.Files, err = loadList("symlink", "files", cfg)
+	if err != nil {
+		return err
+	} else if r.Files == nil {
+		return fmt.Errorf("[symlink] no \"files\" key in config\n")
+	}
+
+	return nil
+}
+
+func (r *Symlink) GetConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"files": r.Files,
+	}
+}
+
+func (r *Symlink) Evaluate(dir string) (bool, error) {
+	for _, file := range r.Files {
+		log.Debug("   [symlink] %s\n", file)
+
+		// Clean the path so that "." and trailing slashes do not cause the
+		// link to be followed.
+		info, err := os.Lstat(filepath.Clean(filepath.Join(dir, file)))
+
+		if err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return true, nil
+		} else if err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
+// tags add linked symlink .
+func (r *Symlink) Add(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("No files specified.")
+	}
+
+	r.Files = append(r.Files, args...)
+
+	return nil
+}
+
+func (r *Symlink) Del(args []string) error {
+	if len(args) == 0 {
+		r.Files = make([]string, 0)
+	} else {
+		r.Files = removeAll(r.Files, args)
+	}
+
+	return nil
+}
+
+func (r *Symlink) String() string {
+	s := ""
+	for _, file := range r.Files {
+		s += fmt.Sprintf("\n[symlink] %s", file)
+	}
+
+	return strings.TrimLeft(s, "\n")
+}
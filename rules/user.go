@@ -0,0 +1,91 @@
+package rules
+
+import (
+	"fmt"
+	"os/user"
+	"path"
+	"strings"
+
+	"github.com/mecha/tags/log"
+)
+
+type (
+	User struct {
+		Users []string
+	}
+)
+
+func init() {
+	register("user", "Matches on the current user's name.", func() Rule { return &User{} },
+		Field{Name: "users", Type: ListField, Description: "Username patterns.", Required: true},
+	)
+}
+
+func (r *User) Load(cfg map[string]interface{}) error {
+	var err error
+
+	r.Users, err = loadList("user", "users", cfg)
+	if err != nil {
+		return err
+	} else if r.Users == nil {
+		return fmt.Errorf("[user] no \"users\" key in config\n")
+	}
+
+	return nil
+}
+
+func (r *User) GetConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"users": r.Users,
+	}
+}
+
+func (r *User) Evaluate(dir string) (bool, error) {
+	current, err := user.Current()
+	if err != nil {
+		return false, err
+	}
+
+	for _, pattern := range r.Users {
+		log.Debug("   [user] %s\n", pattern)
+
+		match, err := path.Match(pattern, current.Username)
+		if err != nil {
+			return false, err
+		} else if match {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// tags add admin user root
+func (r *User) Add(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("No users specified.")
+	}
+
+	r.Users = append(r.Users, args...)
+
+	return nil
+}
+
+func (r *User) Del(args []string) error {
+	if len(args) == 0 {
+		r.Users = make([]string, 0)
+	} else {
+		r.Users = removeAll(r.Users, args)
+	}
+
+	return nil
+}
+
+func (r *User) String() string {
+	s := ""
+	for _, name := range r.Users {
+		s += fmt.Sprintf("\n[user] %s", name)
+	}
+
+	return strings.TrimLeft(s, "\n")
+}
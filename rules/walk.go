@@ -0,0 +1,245 @@
+package rules
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mecha/tags/utils"
+)
+
+type (
+	// walker walks a directory tree within some limits, skipping vendored
+	// and ignored directories unless All is set. A MaxDepth of 1 only visits
+	// the directory's own entries. Zero values mean no limit.
+	//
+	// Include holds the patterns that the walk is looking for. Vendored and
+	// ignored directories that these patterns name explicitly are not skipped,
+	// so that a pattern such as "node_modules/*/package.json" can match.
+	walker struct {
+		MaxDepth int
+		MaxFiles int
+		Ignore   []string
+		Include  []string
+		All      bool
+	}
+)
+
+// vendoredDirs are directories that contain third-party or generated files,
+// and are skipped when walking.
+var vendoredDirs = map[string]bool{
+	".git":             true,
+	".hg":              true,
+	".svn":             true,
+	".cache":           true,
+	".direnv":          true,
+	".gradle":          true,
+	".mypy_cache":      true,
+	".next":            true,
+	".terraform":       true,
+	".tox":             true,
+	".venv":            true,
+	"__pycache__":      true,
+	"bower_components": true,
+	"node_modules":     true,
+	"target":           true,
+	"third_party":      true,
+	"vendor":           true,
+	"venv":             true,
+}
+
+// errStopWalk is returned by walk callbacks to end the walk early.
+var errStopWalk = errors.New("stop walk")
+
+// walk calls fn for every file and directory under dir, except dir itself.
+// The path passed to fn is relative to dir and slash-separated. Returning
+// errStopWalk from fn ends the walk without an error.
+func (w walker) walk(dir string, fn func(rel string, d fs.DirEntry) error) error {
+	ignore, err := compileGlobs(w.Ignore)
+	if err != nil {
+		return err
+	}
+
+	if !w.All {
+		ignore = append(readGitignore(dir), ignore...)
+	}
+
+	keep, err := includedDirs(w.Include)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Skip unreadable entries rather than failing the whole walk.
+			if p != dir && d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if p == dir {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		rel = filepath.ToSlash(rel)
+		depth := strings.Count(rel, "/") + 1
+
+		skip := (d.IsDir() && !w.All && vendoredDirs[d.Name()]) || ignore.matches(rel)
+
+		if skip && d.IsDir() && !keep.matches(rel) {
+			return fs.SkipDir
+		} else if skip && !d.IsDir() {
+			return nil
+		}
+
+		count++
+		if w.MaxFiles > 0 && count > w.MaxFiles {
+			return errStopWalk
+		}
+
+		if err := fn(rel, d); err != nil {
+			return err
+		}
+
+		if d.IsDir() && w.MaxDepth > 0 && depth >= w.MaxDepth {
+			return fs.SkipDir
+		}
+
+		return nil
+	})
+
+	if err == errStopWalk {
+		return nil
+	}
+
+	return err
+}
+
+// globSet is a list of compiled glob patterns, so that the patterns are not
+// compiled again for every entry in a walk.
+type globSet []globMatcher
+
+type globMatcher struct {
+	re *regexp.Regexp
+
+	// full is true if the pattern matches the whole relative path, rather
+	// than only the entry's name.
+	full bool
+}
+
+// compileGlobs compiles glob patterns for matching relative paths with
+// globSet.matches.
+func compileGlobs(patterns []string) (globSet, error) {
+	set := make(globSet, 0, len(patterns))
+
+	for _, pattern := range patterns {
+		re, err := utils.CompileGlob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern \"%s\": %s", pattern, err)
+		}
+
+		set = append(set, globMatcher{re: re, full: strings.Contains(pattern, "/")})
+	}
+
+	return set, nil
+}
+
+// includedDirs compiles matchers for the directories that patterns name
+// explicitly: for each segment of a pattern without glob characters, the path
+// up to and including that segment. Segments after a "**" are not included,
+// since they may match at any depth.
+func includedDirs(patterns []string) (globSet, error) {
+	set := make(globSet, 0)
+
+	for _, pattern := range patterns {
+		segments := strings.Split(pattern, "/")
+
+		for i, segment := range segments {
+			if strings.Contains(segment, "**") {
+				break
+			}
+
+			if segment == "" || segment == "." || utils.HasGlobMeta(segment) {
+				continue
+			}
+
+			re, err := utils.CompileGlob(strings.Join(segments[:i+1], "/"))
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern \"%s\": %s", pattern, err)
+			}
+
+			set = append(set, globMatcher{re: re, full: true})
+		}
+	}
+
+	return set, nil
+}
+
+// matches reports whether a relative path matches any of the patterns.
+// Patterns without a "/" match the entry's name at any depth, and other
+// patterns match the path from the walked directory.
+func (set globSet) matches(rel string) bool {
+	name := path.Base(rel)
+
+	for _, m := range set {
+		target := name
+		if m.full {
+			target = rel
+		}
+
+		if m.re.MatchString(target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readGitignore reads the simple patterns from a directory's .gitignore file.
+// Negations are not supported and are skipped, and anchoring slashes are
+// removed since patterns are always matched relative to the directory.
+func readGitignore(dir string) globSet {
+	file, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	patterns := make(globSet, 0)
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+			continue
+		}
+
+		line = strings.TrimSuffix(strings.TrimPrefix(line, "/"), "/")
+		if line == "" {
+			continue
+		}
+
+		// Lines that are not valid globs are skipped, rather than making every
+		// walk of the directory fail.
+		if set, err := compileGlobs([]string{line}); err == nil {
+			patterns = append(patterns, set...)
+		}
+	}
+
+	return patterns
+}
@@ -0,0 +1,154 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mecha/tags/log"
+	"github.com/mecha/tags/utils"
+)
+
+type (
+	// Xattr matches directories by their extended attributes. Tags are
+	// looked up in the "user.xdg.tags" attribute, which file managers use
+	// for user-assigned tags. Attrs maps attribute names to values, where an
+	// empty value only requires the attribute to be set.
+	Xattr struct {
+		Tags  []string
+		Attrs map[string]string
+	}
+)
+
+func init() {
+	register("xattr", "Matches on the directory's extended attributes.", func() Rule { return &Xattr{} },
+		Field{Name: "tags", Type: ListField, Description: "Tags that must be in the user.xdg.tags attribute."},
+		Field{Name: "attrs", Type: MapField, Description: "Maps attributes to the values they must have."},
+	)
+}
+
+func (r *Xattr) Load(cfg map[string]interface{}) error {
+	var err error
+
+	if r.Tags, err = loadList("xattr", "tags", cfg); err != nil {
+		return err
+	}
+	if r.Attrs, err = loadMap("xattr", "attrs", cfg); err != nil {
+		return err
+	}
+
+	if r.Tags == nil && r.Attrs == nil {
+		return fmt.Errorf("[xattr] config needs at least one of \"tags\" or \"attrs\"\n")
+	}
+
+	return nil
+}
+
+func (r *Xattr) GetConfig() map[string]interface{} {
+	cfg := map[string]interface{}{}
+
+	if len(r.Tags) > 0 {
+		cfg["tags"] = r.Tags
+	}
+	if len(r.Attrs) > 0 {
+		cfg["attrs"] = r.Attrs
+	}
+
+	return cfg
+}
+
+func (r *Xattr) Evaluate(dir string) (bool, error) {
+	if len(r.Tags) > 0 {
+		found, err := utils.ReadXDGTags(dir)
+		if err != nil {
+			log.Debug("   [xattr] %s\n", err)
+			return false, nil
+		}
+
+		for _, tag := range r.Tags {
+			log.Debug("   [xattr] tag %s\n", tag)
+
+			if contains(found, tag) {
+				return true, nil
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(r.Attrs) {
+		expected := r.Attrs[name]
+		log.Debug("   [xattr] %s=%s\n", name, expected)
+
+		value, ok, err := utils.GetXattr(dir, name)
+		if err != nil {
+			log.Debug("   [xattr] %s\n", err)
+			return false, nil
+		}
+
+		if ok && (expected == "" || value == expected) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Arguments that start with "user." are attributes, optionally followed by
+// "=<VALUE>". All other arguments are tags.
+//
+// tags add work xattr work
+// tags add project xattr user.project=acme
+func (r *Xattr) Add(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("No tags or attributes specified.")
+	}
+
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "user.") {
+			r.Tags = append(r.Tags, arg)
+			continue
+		}
+
+		if r.Attrs == nil {
+			r.Attrs = make(map[string]string)
+		}
+
+		name, value, _ := strings.Cut(arg, "=")
+		r.Attrs[name] = value
+	}
+
+	return nil
+}
+
+func (r *Xattr) Del(args []string) error {
+	if len(args) == 0 {
+		r.Tags = nil
+		r.Attrs = nil
+		return nil
+	}
+
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "user.") {
+			name, _, _ := strings.Cut(arg, "=")
+			delete(r.Attrs, name)
+		} else {
+			r.Tags = removeAll(r.Tags, []string{arg})
+		}
+	}
+
+	return nil
+}
+
+func (r *Xattr) String() string {
+	s := ""
+	for _, tag := range r.Tags {
+		s += fmt.Sprintf("\n[xattr] %s", tag)
+	}
+	for _, name := range sortedKeys(r.Attrs) {
+		if value := r.Attrs[name]; value != "" {
+			s += fmt.Sprintf("\n[xattr] %s=%s", name, value)
+		} else {
+			s += fmt.Sprintf("\n[xattr] %s", name)
+		}
+	}
+
+	return strings.TrimLeft(s, "\n")
+}
@@ -0,0 +1,87 @@
+package tags
+
+import (
+	"fmt"
+	"strings"
+)
+
+type (
+	// Expr is a parsed tag expression. It matches when any of its alternatives
+	// match, and an alternative matches when all of its terms match.
+	Expr [][]exprTerm
+
+	exprTerm struct {
+		name   string
+		negate bool
+	}
+)
+
+// ParseExpr parses a tag expression. Alternatives are separated by ",", and
+// tags that must all match are joined with "+". A tag prefixed with "!" must
+// not match. For example, "go+docker,!node" matches directories that are
+// either tagged with both "go" and "docker", or are not tagged with "node".
+func ParseExpr(expr string, cfg map[string]Tag) (Expr, error) {
+	result := make(Expr, 0)
+
+	for _, alt := range strings.Split(expr, ",") {
+		terms := make([]exprTerm, 0)
+
+		for _, name := range strings.Split(alt, "+") {
+			name = strings.TrimSpace(name)
+			term := exprTerm{name: name}
+
+			if strings.HasPrefix(name, "!") {
+				term.name = strings.TrimSpace(name[1:])
+				term.negate = true
+			}
+
+			if term.name == "" {
+				return nil, fmt.Errorf("Invalid tag expression: \"%s\"", expr)
+			}
+
+			if _, ok := cfg[term.name]; !ok {
+				return nil, fmt.Errorf("Tag \"%s\" not found.", term.name)
+			}
+
+			terms = append(terms, term)
+		}
+
+		result = append(result, terms)
+	}
+
+	return result, nil
+}
+
+// Match reports whether a directory matches the expression. The tags in
+// always match without checking their rules, such as the tags that local
+// config files assign.
+func (expr Expr) Match(dir string, cfg map[string]Tag, always []string) bool {
+	for _, terms := range expr {
+		match := true
+
+		for _, term := range terms {
+			tag := cfg[term.name]
+
+			if (contains(always, term.name) || IsMatch(dir, &tag)) == term.negate {
+				match = false
+				break
+			}
+		}
+
+		if match {
+			return true
+		}
+	}
+
+	return false
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
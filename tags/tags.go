@@ -54,18 +54,20 @@ func (tag *Tag) AddRule(ruleType string, args []string) error {
 	idx := tag.findRule(ruleType)
 
 	if idx >= 0 {
-		tag.Rules[idx].Add(args)
-	} else {
-		newRule, err := rules.New(ruleType)
+		return tag.Rules[idx].Add(args)
+	}
 
-		if err != nil {
-			return err
-		}
+	newRule, err := rules.New(ruleType)
+	if err != nil {
+		return err
+	}
 
-		newRule.Add(args)
-		tag.Rules = append(tag.Rules, newRule)
+	if err = newRule.Add(args); err != nil {
+		return err
 	}
 
+	tag.Rules = append(tag.Rules, newRule)
+
 	return nil
 }
 
@@ -79,3 +81,14 @@ func (tag *Tag) DelRule(ruleType string, args []string) error {
 
 	return fmt.Errorf("Rule \"%s\" not found.", ruleType)
 }
+
+// SetRule adds a rule to the tag, replacing any existing rule of the same type.
+func (tag *Tag) SetRule(rule rules.Rule) {
+	idx := tag.findRule(rules.GetType(rule))
+
+	if idx >= 0 {
+		tag.Rules[idx] = rule
+	} else {
+		tag.Rules = append(tag.Rules, rule)
+	}
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"strings"
+)
+
+// DiffLines compares two texts line by line and returns the lines that differ,
+// prefixed with "-" for removed lines and "+" for added lines, along with up to
+// context unchanged lines around them, prefixed with " ". Separate groups of
+// changes are separated by a "..." line.
+func DiffLines(before string, after string, context int) []string {
+	a := splitLines(before)
+	b := splitLines(after)
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and
+	// b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	lines := make([]string, 0, len(a)+len(b))
+	i, j := 0, 0
+
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, " "+a[i])
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "-"+a[i])
+			i++
+		default:
+			lines = append(lines, "+"+b[j])
+			j++
+		}
+	}
+
+	return trimContext(lines, context)
+}
+
+// trimContext keeps only the changed lines and up to context unchanged lines
+// around them.
+func trimContext(lines []string, context int) []string {
+	keep := make([]bool, len(lines))
+
+	for i, line := range lines {
+		if line[0] == ' ' {
+			continue
+		}
+
+		for k := i - context; k <= i+context; k++ {
+			if k >= 0 && k < len(lines) {
+				keep[k] = true
+			}
+		}
+	}
+
+	result := make([]string, 0)
+	skipped := false
+
+	for i, line := range lines {
+		if !keep[i] {
+			skipped = true
+			continue
+		}
+
+		if skipped && len(result) > 0 {
+			result = append(result, "...")
+		}
+
+		skipped = false
+		result = append(result, line)
+	}
+
+	return result
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationUnits = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// ParseDuration parses a duration such as "7d", "2w" or "1d12h". It supports
+// the "s", "m", "h", "d" (day) and "w" (week) units.
+func ParseDuration(str string) (time.Duration, error) {
+	s := strings.TrimSpace(str)
+	if s == "" {
+		return 0, fmt.Errorf("Invalid duration: \"%s\"", str)
+	}
+
+	total := time.Duration(0)
+
+	for s != "" {
+		end := 0
+		for end < len(s) && (s[end] >= '0' && s[end] <= '9' || s[end] == '.') {
+			end++
+		}
+
+		num, err := strconv.ParseFloat(s[:end], 64)
+		if err != nil || end == len(s) {
+			return 0, fmt.Errorf("Invalid duration: \"%s\"", str)
+		}
+
+		unit, ok := durationUnits[s[end:end+1]]
+		if !ok {
+			return 0, fmt.Errorf("Invalid duration unit in \"%s\"", str)
+		}
+
+		total += time.Duration(num * float64(unit))
+		s = s[end+1:]
+	}
+
+	return total, nil
+}
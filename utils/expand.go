@@ -0,0 +1,218 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"github.com/OpenPeeDeeP/xdg"
+)
+
+type (
+	// Expander expands environment variables and home directories in
+	// strings, such as the values in rule configs.
+	Expander struct {
+		// LookupEnv is used to read environment variables. If nil, the
+		// process' environment is used.
+		LookupEnv func(key string) (string, bool)
+
+		// Strict makes undefined variables without a default an error,
+		// instead of expanding them to an empty string.
+		Strict bool
+	}
+)
+
+// xdgDefaults are the values of the XDG base directory variables when they
+// are not set, as defined by the XDG base directory specification.
+var xdgDefaults = map[string]func() string{
+	"XDG_DATA_HOME":   xdg.DataHome,
+	"XDG_CONFIG_HOME": xdg.ConfigHome,
+	"XDG_CACHE_HOME":  xdg.CacheHome,
+	"XDG_STATE_HOME": func() string {
+		home, _ := os.UserHomeDir()
+		return filepath.Join(home, ".local", "state")
+	},
+	"XDG_DATA_DIRS": func() string {
+		return strings.Join(xdg.DataDirs(), string(os.PathListSeparator))
+	},
+	"XDG_CONFIG_DIRS": func() string {
+		return strings.Join(xdg.ConfigDirs(), string(os.PathListSeparator))
+	},
+}
+
+// Expand expands a string using the process' environment. Undefined variables
+// expand to an empty string. See Expander.Expand.
+func Expand(str string) (string, error) {
+	return Expander{}.Expand(str)
+}
+
+// Expand expands the following in a string:
+//
+//   - "$VAR" and "${VAR}" to the value of an environment variable.
+//   - "${VAR:-default}" to the value of a variable, or the default if the
+//     variable is unset or empty. The default is expanded too.
+//   - The XDG base directory variables, such as "$XDG_DATA_HOME", to their
+//     default values if they are not set.
+//   - A leading "~" to the current user's home directory, and a leading
+//     "~user" to that user's home directory.
+//   - "$$" to a literal "$".
+//
+// A "$" that is not followed by a variable name or "{" is kept as is.
+func (e Expander) Expand(str string) (string, error) {
+	str, err := e.expandTilde(str)
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.Contains(str, "$") {
+		return str, nil
+	}
+
+	sb := strings.Builder{}
+
+	for i := 0; i < len(str); i++ {
+		if str[i] != '$' || i+1 == len(str) {
+			sb.WriteByte(str[i])
+			continue
+		}
+
+		switch next := str[i+1]; {
+		case next == '$':
+			sb.WriteByte('$')
+			i++
+		case next == '{':
+			end := matchingBrace(str, i+1)
+			if end < 0 {
+				return "", fmt.Errorf("Unterminated \"${\" in \"%s\"", str)
+			}
+
+			val, err := e.expandBraces(str[i+2 : end])
+			if err != nil {
+				return "", err
+			}
+
+			sb.WriteString(val)
+			i = end
+		case isNameChar(next, true):
+			end := i + 1
+			for end < len(str) && isNameChar(str[end], false) {
+				end++
+			}
+
+			val, err := e.lookup(str[i+1:end], nil)
+			if err != nil {
+				return "", err
+			}
+
+			sb.WriteString(val)
+			i = end - 1
+		default:
+			sb.WriteByte('$')
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// expandBraces expands the contents of a "${...}" expression.
+func (e Expander) expandBraces(expr string) (string, error) {
+	name, def, hasDef := strings.Cut(expr, ":-")
+
+	if name == "" || !isNameChar(name[0], true) || strings.IndexFunc(name, func(r rune) bool {
+		return r > 127 || !isNameChar(byte(r), false)
+	}) >= 0 {
+		return "", fmt.Errorf("Invalid variable name in \"${%s}\"", expr)
+	}
+
+	if !hasDef {
+		return e.lookup(name, nil)
+	}
+
+	return e.lookup(name, &def)
+}
+
+// lookup returns the value of a variable. An unset or empty variable yields
+// the expanded default if there is one, or the XDG default for the XDG
+// variables.
+func (e Expander) lookup(name string, def *string) (string, error) {
+	lookupEnv := e.LookupEnv
+	if lookupEnv == nil {
+		lookupEnv = os.LookupEnv
+	}
+
+	val, ok := lookupEnv(name)
+
+	switch {
+	case ok && (val != "" || def == nil):
+		return val, nil
+	case def != nil:
+		return e.Expand(*def)
+	}
+
+	if xdgDefault, isXDG := xdgDefaults[name]; isXDG {
+		return xdgDefault(), nil
+	}
+
+	if e.Strict {
+		return "", fmt.Errorf("Undefined variable \"%s\"", name)
+	}
+
+	return "", nil
+}
+
+// expandTilde expands a leading "~" or "~user".
+func (e Expander) expandTilde(str string) (string, error) {
+	if !strings.HasPrefix(str, "~") {
+		return str, nil
+	}
+
+	name, rest, _ := strings.Cut(str[1:], "/")
+	if rest != "" || strings.HasSuffix(str, "/") {
+		rest = "/" + rest
+	}
+
+	var (
+		home string
+		err  error
+	)
+
+	if name == "" {
+		home, err = os.UserHomeDir()
+	} else {
+		var u *user.User
+		if u, err = user.Lookup(name); err == nil {
+			home = u.HomeDir
+		}
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("Could not expand \"~%s\": %s", name, err)
+	}
+
+	return home + rest, nil
+}
+
+// matchingBrace returns the index of the "}" that closes the "{" at start,
+// allowing nested "${...}" in defaults, or -1 if there is none.
+func matchingBrace(str string, start int) int {
+	depth := 0
+
+	for i := start; i < len(str); i++ {
+		switch str[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+func isNameChar(c byte, first bool) bool {
+	return c == '_' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (!first && c >= '0' && c <= '9')
+}
@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"os"
+	"os/user"
+	"testing"
+
+	"github.com/OpenPeeDeeP/xdg"
+)
+
+func TestExpand(t *testing.T) {
+	env := map[string]string{
+		"HOME":  "/home/me",
+		"EMPTY": "",
+		"NAME":  "tags",
+	}
+
+	e := Expander{
+		LookupEnv: func(key string) (string, bool) {
+			val, ok := env[key]
+			return val, ok
+		},
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		str  string
+		want string
+	}{
+		{"plain/path", "plain/path"},
+		{"$HOME/src", "/home/me/src"},
+		{"${HOME}/src", "/home/me/src"},
+		{"$NAME.json", "tags.json"},
+		{"${NAME}_1", "tags_1"},
+		{"$NAME_1", ""},
+		{"$UNSET/src", "/src"},
+		{"${UNSET:-/tmp}/src", "/tmp/src"},
+		{"${EMPTY:-/tmp}", "/tmp"},
+		{"${NAME:-other}", "tags"},
+		{"${UNSET:-$HOME/${NAME}}", "/home/me/tags"},
+		{"${UNSET:-}", ""},
+		{"$$HOME", "$HOME"},
+		{"a$$b", "a$b"},
+		{"cost: 5$", "cost: 5$"},
+		{"$ and $1", "$ and $1"},
+		{"~", home},
+		{"~/src", home + "/src"},
+		{"~/", home + "/"},
+		{"a/~/b", "a/~/b"},
+		{"$XDG_CONFIG_HOME/tags", xdg.ConfigHome() + "/tags"},
+		{"$XDG_DATA_HOME", xdg.DataHome()},
+		{"$XDG_CACHE_HOME", xdg.CacheHome()},
+		{"$XDG_STATE_HOME", home + "/.local/state"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.str, func(t *testing.T) {
+			got, err := e.Expand(test.str)
+			if err != nil {
+				t.Fatalf("Expand() error: %s", err)
+			}
+
+			if got != test.want {
+				t.Errorf("Expand() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestExpandXDGSet(t *testing.T) {
+	e := Expander{
+		LookupEnv: func(key string) (string, bool) {
+			if key == "XDG_CONFIG_HOME" {
+				return "/etc/me", true
+			}
+			return "", false
+		},
+	}
+
+	if got, err := e.Expand("$XDG_CONFIG_HOME/tags"); err != nil || got != "/etc/me/tags" {
+		t.Errorf("Expand() = %q, %v, want %q", got, err, "/etc/me/tags")
+	}
+}
+
+func TestExpandUser(t *testing.T) {
+	u, err := user.Current()
+	if err != nil {
+		t.Skipf("Could not get the current user: %s", err)
+	}
+
+	if got, err := (Expander{}).Expand("~" + u.Username + "/src"); err != nil || got != u.HomeDir+"/src" {
+		t.Errorf("Expand() = %q, %v, want %q", got, err, u.HomeDir+"/src")
+	}
+
+	if _, err := (Expander{}).Expand("~no-such-user-for-tags/src"); err == nil {
+		t.Errorf("Expand() of an unknown user did not fail")
+	}
+}
+
+func TestExpandErrors(t *testing.T) {
+	e := Expander{
+		LookupEnv: func(key string) (string, bool) {
+			if key == "SET" {
+				return "value", true
+			}
+			return "", false
+		},
+		Strict: true,
+	}
+
+	tests := []struct {
+		str     string
+		wantErr bool
+	}{
+		{"$SET", false},
+		{"${UNSET:-default}", false},
+		{"$$UNSET", false},
+		{"$XDG_DATA_HOME", false},
+		{"$UNSET", true},
+		{"${UNSET}", true},
+		{"${UNSET:-$OTHER}", true},
+		{"${SET", true},
+		{"${}", true},
+		{"${1A}", true},
+		{"${A-B}", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.str, func(t *testing.T) {
+			_, err := e.Expand(test.str)
+			if (err != nil) != test.wantErr {
+				t.Errorf("Expand() error = %v, want an error: %v", err, test.wantErr)
+			}
+		})
+	}
+
+	// Without Strict, only syntax errors fail.
+	e.Strict = false
+	if got, err := e.Expand("$UNSET"); err != nil || got != "" {
+		t.Errorf("Expand() = %q, %v, want an empty string", got, err)
+	}
+}
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+// CompileGlob converts a glob pattern into a regular expression that matches
+// whole, slash-separated paths. "*" and "?" do not match path separators,
+// while "**" matches across them. Character classes ("[a-z]") and
+// alternatives ("{yml,yaml}") are also supported.
+func CompileGlob(pattern string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+
+	inAlt := false
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+
+		switch c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				// Let "**/" also match zero directories.
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					i++
+					sb.WriteString("(?:.*/)?")
+				} else {
+					sb.WriteString(".*")
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + class + "]")
+			i += end
+		case '{':
+			inAlt = true
+			sb.WriteString("(?:")
+		case '}':
+			if inAlt {
+				inAlt = false
+				sb.WriteString(")")
+			} else {
+				sb.WriteString(`\}`)
+			}
+		case ',':
+			if inAlt {
+				sb.WriteString("|")
+			} else {
+				sb.WriteString(",")
+			}
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				sb.WriteString(regexp.QuoteMeta(string(pattern[i])))
+			}
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	sb.WriteString("$")
+
+	return regexp.Compile(sb.String())
+}
+
+// MatchGlob reports whether a slash-separated path matches a glob pattern.
+// See CompileGlob for the supported syntax.
+func MatchGlob(pattern string, path string) (bool, error) {
+	re, err := CompileGlob(pattern)
+	if err != nil {
+		return false, err
+	}
+
+	return re.MatchString(path), nil
+}
+
+// HasGlobMeta reports whether a string contains any glob special characters.
+func HasGlobMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[{\`)
+}
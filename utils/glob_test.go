@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestCompileGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/main.go", false},
+		{"*.go", "main.go.bak", false},
+		{"src/*", "src/main.go", true},
+		{"src/*", "src/pkg/main.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "cmd/tags/main.go", true},
+		{"src/**", "src/pkg/main.go", true},
+		{"src/**/test", "src/test", true},
+		{"src/**/test", "src/a/b/test", true},
+		{"src/**/test", "srctest", false},
+		{"?.txt", "a.txt", true},
+		{"?.txt", "ab.txt", false},
+		{"?.txt", "/.txt", false},
+		{"[a-c].txt", "b.txt", true},
+		{"[a-c].txt", "d.txt", false},
+		{"[!a-c].txt", "d.txt", true},
+		{"[!a-c].txt", "a.txt", false},
+		{"*.{yml,yaml}", "config.yaml", true},
+		{"*.{yml,yaml}", "config.yml", true},
+		{"*.{yml,yaml}", "config.json", false},
+		{"a,b", "a,b", true},
+		{"a}", "a}", true},
+		{"[abc", "[abc", true},
+		{`\*.go`, "*.go", true},
+		{`\*.go`, "main.go", false},
+		{"a.b", "aXb", false},
+		{"a+b", "a+b", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.pattern+" "+test.path, func(t *testing.T) {
+			re, err := CompileGlob(test.pattern)
+			if err != nil {
+				t.Fatalf("CompileGlob() error: %s", err)
+			}
+
+			if got := re.MatchString(test.path); got != test.want {
+				t.Errorf("CompileGlob(%q) matches %q = %v, want %v", test.pattern, test.path, got, test.want)
+			}
+		})
+	}
+}
+
+func TestHasGlobMeta(t *testing.T) {
+	tests := map[string]bool{
+		"main.go":      false,
+		"/var/www":     false,
+		"*.go":         true,
+		"?.txt":        true,
+		"[ab].txt":     true,
+		"*.{yml,yaml}": true,
+		`a\b`:          true,
+	}
+
+	for pattern, want := range tests {
+		if got := HasGlobMeta(pattern); got != want {
+			t.Errorf("HasGlobMeta(%q) = %v, want %v", pattern, got, want)
+		}
+	}
+}
@@ -1,24 +1,34 @@
 package utils
 
 import (
-	"fmt"
-	"os"
 	"path/filepath"
 	"strings"
 )
 
-func ExpandTilde(path string) (string, error) {
-	if strings.HasPrefix(path, "~") {
-		homeDir, err := os.UserHomeDir()
+// IsWithin reports whether path is base itself, or is nested inside it. Both
+// paths are compared segment by segment, so "/var/www-old" is not within
+// "/var/www". The paths should be absolute.
+func IsWithin(path string, base string) bool {
+	rel, err := filepath.Rel(filepath.Clean(base), filepath.Clean(path))
+	if err != nil {
+		return false
+	}
 
-		if err != nil {
-			return "", fmt.Errorf("Could not expand tilde in path:\n%s", err)
-		}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
 
-        fullPath := filepath.Join(homeDir, path[1:])
+// Ancestors returns a path followed by each of its parent directories, up to
+// and including the root.
+func Ancestors(path string) []string {
+	result := []string{path}
+
+	for {
+		parent := filepath.Dir(path)
+		if parent == path {
+			return result
+		}
 
-		return fullPath, nil
-	} else {
-		return path, nil
+		result = append(result, parent)
+		path = parent
 	}
 }
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestIsWithin(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		base string
+		want bool
+	}{
+		{"same", "/var/www", "/var/www", true},
+		{"nested", "/var/www/site/public", "/var/www", true},
+		{"sibling with prefix", "/var/www-old", "/var/www", false},
+		{"nested in sibling with prefix", "/var/www-old/site", "/var/www", false},
+		{"parent", "/var", "/var/www", false},
+		{"unrelated", "/home/user", "/var/www", false},
+		{"trailing slash on base", "/var/www/site", "/var/www/", true},
+		{"trailing slash on path", "/var/www/", "/var/www", true},
+		{"dot segments", "/var/www/site/../other", "/var/www", true},
+		{"dot segments leaving base", "/var/www/../www-old", "/var/www", false},
+		{"name starting with dots", "/var/www/..cache", "/var/www", true},
+		{"root", "/var/www", "/", true},
+		{"relative", "src/pkg", "src", true},
+		{"relative sibling with prefix", "src-old", "src", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path, base := filepath.FromSlash(test.path), filepath.FromSlash(test.base)
+
+			if got := IsWithin(path, base); got != test.want {
+				t.Errorf("IsWithin(%q, %q) = %v, want %v", path, base, got, test.want)
+			}
+		})
+	}
+}
+
+func TestAncestors(t *testing.T) {
+	got := Ancestors(filepath.FromSlash("/var/www/site"))
+	want := []string{
+		filepath.FromSlash("/var/www/site"),
+		filepath.FromSlash("/var/www"),
+		filepath.FromSlash("/var"),
+		filepath.FromSlash("/"),
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Ancestors() = %v, want %v", got, want)
+	}
+}
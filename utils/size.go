@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var sizeUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"T", 1 << 40},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+}
+
+// ParseSize parses a size in bytes, optionally with a binary unit suffix such
+// as "K", "M", "G" or "T". A trailing "B" or "iB" is allowed, as in "10MB" or
+// "10MiB".
+func ParseSize(str string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(str))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+
+	mult := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(s, unit.suffix) {
+			mult = unit.size
+			s = strings.TrimSuffix(s, unit.suffix)
+			break
+		}
+	}
+
+	num, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || num < 0 {
+		return 0, fmt.Errorf("Invalid size: \"%s\"", str)
+	}
+
+	return int64(num * float64(mult)), nil
+}
+
+// FormatSize formats a size in bytes using the largest unit that represents
+// it exactly, so that the result can be parsed back by ParseSize.
+func FormatSize(size int64) string {
+	for _, unit := range sizeUnits {
+		if size != 0 && size%unit.size == 0 {
+			return fmt.Sprintf("%d%s", size/unit.size, unit.suffix)
+		}
+	}
+
+	return strconv.FormatInt(size, 10)
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"strings"
+)
+
+// XDGTagsAttr is the extended attribute that file managers use to store
+// user-assigned tags, as a comma-separated list.
+const XDGTagsAttr = "user.xdg.tags"
+
+// ReadXDGTags returns the tags in a path's "user.xdg.tags" attribute.
+func ReadXDGTags(path string) ([]string, error) {
+	value, ok, err := GetXattr(path, XDGTagsAttr)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	tags := make([]string, 0)
+
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags, nil
+}
+
+// WriteXDGTags sets a path's "user.xdg.tags" attribute, removing it if there
+// are no tags.
+func WriteXDGTags(path string, tags []string) error {
+	if len(tags) == 0 {
+		return RemoveXattr(path, XDGTagsAttr)
+	}
+
+	return SetXattr(path, XDGTagsAttr, strings.Join(tags, ","))
+}
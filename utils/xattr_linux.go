@@ -0,0 +1,55 @@
+//go:build linux
+
+package utils
+
+import (
+	"syscall"
+)
+
+// GetXattr reads an extended attribute. The boolean is false if the path
+// does not have the attribute.
+func GetXattr(path string, name string) (string, bool, error) {
+	for {
+		size, err := syscall.Getxattr(path, name, nil)
+		if err == syscall.ENODATA {
+			return "", false, nil
+		} else if err != nil {
+			return "", false, err
+		}
+
+		buf := make([]byte, size)
+		n, err := syscall.Getxattr(path, name, buf)
+
+		// The attribute may have grown between the two calls. An empty buffer
+		// only queries the size again, so a larger n is not an error either.
+		if err == syscall.ERANGE {
+			continue
+		} else if err == syscall.ENODATA {
+			return "", false, nil
+		} else if err != nil {
+			return "", false, err
+		}
+
+		if n > len(buf) {
+			continue
+		}
+
+		return string(buf[:n]), true, nil
+	}
+}
+
+// SetXattr sets an extended attribute, creating or replacing it.
+func SetXattr(path string, name string, value string) error {
+	return syscall.Setxattr(path, name, []byte(value), 0)
+}
+
+// RemoveXattr removes an extended attribute. Removing a missing attribute is
+// not an error.
+func RemoveXattr(path string, name string) error {
+	err := syscall.Removexattr(path, name)
+	if err == syscall.ENODATA {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,25 @@
+//go:build !linux
+
+package utils
+
+import (
+	"fmt"
+	"runtime"
+)
+
+var errXattrUnsupported = fmt.Errorf("Extended attributes are not supported on %s", runtime.GOOS)
+
+// GetXattr is not supported on this platform.
+func GetXattr(path string, name string) (string, bool, error) {
+	return "", false, errXattrUnsupported
+}
+
+// SetXattr is not supported on this platform.
+func SetXattr(path string, name string, value string) error {
+	return errXattrUnsupported
+}
+
+// RemoveXattr is not supported on this platform.
+func RemoveXattr(path string, name string) error {
+	return errXattrUnsupported
+}